@@ -2,6 +2,8 @@ package pgx_collect_test
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -88,3 +90,130 @@ func BenchmarkInitialize(b *testing.B) {
 		})
 	})
 }
+
+func BenchmarkCollectOneRow(b *testing.B) {
+	type Person struct {
+		Name string
+		Age  int64
+	}
+
+	type Record struct {
+		ID int64
+		Person
+		CreatedAt time.Time
+	}
+
+	rows := MakeMockRows(
+		"id,name,age,created_at",
+		OneRow(int64(1), "Alice", int64(30), time.Unix(0, 0)),
+	)
+
+	b.Run("row-to", func(b *testing.B) {
+		rows := MakeMockRows("id", OneRow(int64(1)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			rows.Reset()
+			pgxc.CollectOneRow(rows, pgxc.RowTo[int64])
+		}
+	})
+
+	b.Run("struct-by-name", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			rows.Reset()
+			pgxc.CollectOneRow(rows, pgxc.RowToStructByName[Record])
+		}
+	})
+}
+
+// noScanRows skips scanning values, to measure the overhead of the scanners alone.
+type noScanRows struct {
+	*MockRows
+}
+
+func (noScanRows) Scan(dest ...any) error {
+	return nil
+}
+
+func BenchmarkStructFieldAccess(b *testing.B) {
+	type Wide struct {
+		F0, F1, F2, F3, F4, F5, F6, F7, F8, F9           int64
+		F10, F11, F12, F13, F14, F15, F16, F17, F18, F19 string
+	}
+
+	names := make([]string, 20)
+	row := make([]any, 20)
+	for i := range names {
+		names[i] = fmt.Sprintf("f%d", i)
+		if i < 10 {
+			row[i] = int64(i)
+		} else {
+			row[i] = fmt.Sprint(i)
+		}
+	}
+	data := make([][]any, 1000)
+	for i := range data {
+		data[i] = row
+	}
+	rows := noScanRows{MakeMockRows(strings.Join(names, ","), data)}
+
+	for _, bench := range []struct {
+		name  string
+		rowTo pgxc.RowSpec[Wide]
+	}{
+		{"reflect", pgxc.RowToStructByName[Wide]},
+		{"unsafe", pgxc.RowToStructByNameWith[Wide](pgxc.NewMapper(pgxc.WithUnsafeFieldAccess()))},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			values := make([]Wide, 0, len(data))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				rows.Reset()
+				values, _ = pgxc.AppendRows(values[:0], rows, bench.rowTo)
+			}
+		})
+	}
+}
+
+func BenchmarkInitializeWide(b *testing.B) {
+	type Wide struct {
+		F0, F1, F2, F3, F4, F5, F6, F7, F8, F9           int64
+		F10, F11, F12, F13, F14, F15, F16, F17, F18, F19 int64
+		F20, F21, F22, F23, F24, F25, F26, F27, F28, F29 int64
+		F30, F31, F32, F33, F34, F35, F36, F37, F38, F39 int64
+		F40, F41, F42, F43, F44, F45, F46, F47, F48, F49 int64
+		F50, F51, F52, F53, F54, F55, F56, F57, F58, F59 int64
+		F60, F61, F62, F63, F64, F65, F66, F67, F68, F69 int64
+		F70, F71, F72, F73, F74, F75, F76, F77, F78, F79 int64
+	}
+
+	names := make([]string, 80)
+	for i := range names {
+		names[i] = fmt.Sprintf("f_%d", i)
+	}
+	rows := MakeMockRows(strings.Join(names, ","), nil)
+
+	// Each iteration uses a different subset of the columns, so misses the cache of fields by
+	// column names, but not the cache of type information.
+	rowsets := make([]*MockRows, len(names))
+	for i := range rowsets {
+		subset := append(append([]string(nil), names[:i]...), names[i+1:]...)
+		rowsets[i] = MakeMockRows(strings.Join(subset, ","), nil)
+	}
+
+	var rowTo pgxc.RowSpec[Wide] = pgxc.RowToStructByNameLax[Wide]
+	scanner := rowTo.Scanner()
+	pgxc_internal.ClearStructFieldCaches()
+	scanner.Initialize(rows) // Populate the cache of type information.
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%len(rowsets) == 0 {
+			b.StopTimer()
+			pgxc_internal.ClearStructFieldCaches()
+			scanner.Initialize(rows)
+			b.StartTimer()
+		}
+		scanner.Initialize(rowsets[i%len(rowsets)])
+	}
+}
@@ -0,0 +1,90 @@
+package pgx_collect
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Binding binds a column to a field of a T. Bindings are created with Col.
+type Binding[T any] struct {
+	column string
+	field  func(*T) any
+}
+
+// Col returns a Binding which scans the named column into the value returned by field.
+// field must return a pointer into its argument, e.g.
+//
+//	pgxc.Col("id", func(r *Record) any { return &r.ID })
+func Col[T any](column string, field func(*T) any) Binding[T] {
+	return Binding[T]{column: column, field: field}
+}
+
+// Bind scans a row into a T using explicit column bindings.
+// Each column must have exactly one binding, and each binding must have a column.
+// Columns are matched by exact name.
+//
+// The bindings are checked against the row once per query. Unlike the struct scanners,
+// scanning each row doesn't use reflection, which makes Bind suitable for very hot paths.
+func Bind[T any](cols ...Binding[T]) RowSpec[T] {
+	return func() rowSpecRes[T] {
+		return rowSpecRes[T]{
+			fn: func() Scanner[T] {
+				rs := getPooled[bindScanner[T]]()
+				rs.bindings = cols
+				return rs
+			},
+		}
+	}
+}
+
+type bindScanner[T any] struct {
+	bindings []Binding[T]
+	// fields contains the binding for each column, in column order.
+	fields      []func(*T) any
+	scanTargets []any
+}
+
+var _ Scanner[struct{}] = (*bindScanner[struct{}])(nil)
+
+func (rs *bindScanner[T]) Initialize(rows pgx.Rows) error {
+	fldDescs := rows.FieldDescriptions()
+	rs.fields = make([]func(*T) any, len(fldDescs))
+	for _, b := range rs.bindings {
+		pos := -1
+		for i := range fldDescs {
+			if fldDescs[i].Name == b.column {
+				pos = i
+				break
+			}
+		}
+		if pos == -1 {
+			return fmt.Errorf("cannot find field %s in returned row", b.column)
+		}
+		if rs.fields[pos] != nil {
+			return fmt.Errorf("multiple bindings for row field %s", b.column)
+		}
+		rs.fields[pos] = b.field
+	}
+	for i, f := range rs.fields {
+		if f == nil {
+			return fmt.Errorf("no binding for row field %s", fldDescs[i].Name)
+		}
+	}
+	rs.scanTargets = resizeScanTargets(rs.scanTargets, len(rs.fields))
+	return nil
+}
+
+func (rs *bindScanner[T]) ScanRowInto(receiver *T, rows pgx.Rows) error {
+	for i, f := range rs.fields {
+		rs.scanTargets[i] = f(receiver)
+	}
+	return rows.Scan(rs.scanTargets...)
+}
+
+func (rs *bindScanner[T]) release() {
+	rs.bindings = nil
+	rs.fields = nil
+	rs.scanTargets = clearScanTargets(rs.scanTargets)
+	putPooled(rs)
+}
@@ -0,0 +1,56 @@
+package pgx_collect_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pgxc "github.com/zolstein/pgx-collect"
+	. "github.com/zolstein/pgx-collect/internal/testutils"
+)
+
+func TestBindRowScanner(t *testing.T) {
+	type person struct {
+		Name string
+		Age  int32
+	}
+
+	rowTo := pgxc.Bind(
+		pgxc.Col("name", func(p *person) any { return &p.Name }),
+		pgxc.Col("age", func(p *person) any { return &p.Age }),
+	)
+
+	{
+		rows := MakeMockRows("age,name", [][]any{
+			{int32(30), "Alice"},
+			{int32(25), "Bob"},
+		})
+		expected := []person{{"Alice", 30}, {"Bob", 25}}
+		actual, err := pgxc.CollectRows(rows, rowTo)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+	{
+		rows := MakeMockRows("name", OneRow("Alice"))
+		_, err := pgxc.CollectRows(rows, rowTo)
+		assert.Error(t, err)
+	}
+	{
+		rows := MakeMockRows("name,age,extra", OneRow("Alice", int32(30), nil))
+		_, err := pgxc.CollectRows(rows, rowTo)
+		assert.Error(t, err)
+	}
+	{
+		rows := MakeMockRows("Name,age", OneRow("Alice", int32(30)))
+		_, err := pgxc.CollectRows(rows, rowTo)
+		assert.Error(t, err)
+	}
+	{
+		rows := MakeMockRows("name", OneRow("Alice"))
+		_, err := pgxc.CollectRows(rows, pgxc.Bind(
+			pgxc.Col("name", func(p *person) any { return &p.Name }),
+			pgxc.Col("name", func(p *person) any { return &p.Name }),
+		))
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,83 @@
+// Package pgx_collect provides lower-overhead replacements for the pgx collection functions.
+//
+// # Struct tags
+//
+// The struct scanners (RowToStructByPos, RowToStructByName, etc.) map columns to struct fields
+// using the "db" struct tag, which has the form `db:"name,option,..."`. If the name is "-" the
+// field is ignored. The supported options are:
+//
+//   - prefix: The field must be a struct or a pointer to a struct. Its fields are mapped to
+//     columns as if they were fields of the outer struct, with column names prefixed with the
+//     tag name and "_". E.g. with `db:"addr,prefix"`, the column addr_street maps to the field
+//     Street.
+//   - prefix=p: Like prefix, but column names are prefixed with p.
+//     E.g. with `db:",prefix=addr_"`, the column addr_street maps to the field Street.
+//   - group: Like prefix, but column names aren't prefixed, so the field is mapped like an
+//     embedded struct or pointer to a struct. E.g. with `db:",group"`, the column street maps to
+//     the field Street.
+//   - rest: The field must be a map[string]any or map[string]string. The scanners which map
+//     columns by name store every column which isn't mapped to another field in a new map,
+//     keyed by column name, rather than failing. With map[string]string, columns are scanned
+//     as text and NULL columns are omitted. A struct may have at most one rest field.
+//   - optional: The field may be missing from the row, even for RowToStructByName, etc.
+//   - required: The field must be present in the row, even for RowToStructByNameLax, etc.
+//   - nullzero: NULL is scanned into the field as its zero value, rather than failing if the
+//     field can't represent NULL. WithNullZero applies this to all fields, and RowToNullZero
+//     is the equivalent of RowTo.
+//   - json: The column, typically json or jsonb, is decoded into the field with json.Unmarshal,
+//     or the decoder set with WithJSONDecoder. NULL is decoded as the zero value.
+//   - pos=n: RowToStructByPos, etc. map the field to the column at position n, counting from 0,
+//     rather than mapping fields in declaration order. If any field has a position, every field
+//     must, and the positions must be unique and contiguous. This allows fields to be reordered
+//     without affecting how they're scanned.
+//
+// If the name is empty, e.g. `db:",optional"`, the field's column name is derived from the field
+// name as if it had no tag.
+//
+// Prefixes apply recursively, so a prefixed struct can contain further prefixed structs.
+// A prefixed or grouped pointer to a struct is left nil if all the columns mapped to its fields
+// are NULL, and is allocated otherwise. This is useful for the results of a LEFT JOIN.
+//
+// # Unmarshalers
+//
+// Fields whose types implement encoding.TextUnmarshaler are decoded from text columns (text,
+// varchar, bpchar, name and unknown) with UnmarshalText, and fields whose types implement
+// encoding.BinaryUnmarshaler are decoded from bytea columns with UnmarshalBinary. Columns of other
+// types are scanned by pgx as usual. This doesn't apply to types which pgx can scan into without
+// them, e.g. time.Time or types implementing sql.Scanner. NULL is an error, unless the field is a
+// pointer, in which case it is set to nil.
+//
+// # Converters
+//
+// RegisterConverter registers a function to convert from one type into another. Columns scanned
+// into the second type, by RowTo or as struct fields, are scanned into the first type and then
+// converted. Pointers to the second type are converted too, with NULL scanned as nil.
+// Converters take precedence over UnmarshalText and UnmarshalBinary, but not the json
+// tag option.
+//
+// # Mappers
+//
+// By default, fields without a "db" tag match columns case-insensitively, ignoring underscores.
+// A Mapper can be used to configure this, along with other details of how fields are mapped to
+// columns such as which struct tags are used, and passed to the RowTo...With functions:
+//
+//	var mapper = pgxc.NewMapper(pgxc.WithNameMatching(pgxc.MatchSnakeCase))
+//	...
+//	values, err := pgxc.CollectRows(rows, pgxc.RowToStructByNameWith[Record](mapper))
+//
+// A Mapper with tables registered by WithTables can also match tagged fields to the columns of
+// a specific table, e.g. `db:"users.id"`, to distinguish columns with the same name in a JOIN.
+//
+// # Positional scanning
+//
+// RowToStructByPos, etc. require exactly one column for each field. RowToStructByPosLax, etc.
+// also accept fewer columns, which are mapped to a prefix of the fields, so one struct can be
+// reused by queries selecting different numbers of trailing columns. A Mapper created with
+// WithDiscardExtraColumns also accepts more columns than fields, discarding the extra ones.
+//
+// # Embedded structs
+//
+// The fields of embedded structs are mapped to columns as if they were fields of the outer struct.
+// This includes embedded pointers to structs. An embedded pointer is left nil if all the columns
+// mapped to its fields are NULL, and is allocated otherwise.
+package pgx_collect
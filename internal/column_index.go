@@ -0,0 +1,92 @@
+package pgx_collect
+
+import (
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// columnIndex indexes the columns of a row by name, to find the columns matching each field in
+// constant time. The indexes for folded and table-qualified names are built on first use.
+type columnIndex struct {
+	fldDescs  []pgconn.FieldDescription
+	exact     map[string]colMatches
+	folded    map[string]colMatches
+	qualified map[qualifiedName]colMatches
+}
+
+type qualifiedName struct {
+	name     string
+	tableOID uint32
+}
+
+// colMatches contains the positions of the first two columns matching a name, or -1.
+// The second is only needed to report ambiguous matches.
+type colMatches struct {
+	first  int
+	second int
+}
+
+var noColMatches = colMatches{first: -1, second: -1}
+
+func (c colMatches) add(i int) colMatches {
+	if c.first == -1 {
+		c.first = i
+	} else if c.second == -1 {
+		c.second = i
+	}
+	return c
+}
+
+func newColumnIndex(fldDescs []pgconn.FieldDescription) *columnIndex {
+	idx := &columnIndex{
+		fldDescs: fldDescs,
+		exact:    make(map[string]colMatches, len(fldDescs)),
+	}
+	for i := range fldDescs {
+		addMatch(idx.exact, fldDescs[i].Name, i)
+	}
+	return idx
+}
+
+// lookup returns the positions of the first two columns matching field.
+func (idx *columnIndex) lookup(field fieldName) colMatches {
+	switch {
+	case !field.exactMatch:
+		if idx.folded == nil {
+			idx.folded = make(map[string]colMatches, len(idx.fldDescs))
+			for i := range idx.fldDescs {
+				addMatch(idx.folded, foldName(strings.ReplaceAll(idx.fldDescs[i].Name, "_", "")), i)
+			}
+		}
+		return getMatches(idx.folded, field.name)
+	case field.tableOID != 0:
+		if idx.qualified == nil {
+			idx.qualified = make(map[qualifiedName]colMatches, len(idx.fldDescs))
+			for i := range idx.fldDescs {
+				addMatch(idx.qualified, qualifiedName{idx.fldDescs[i].Name, idx.fldDescs[i].TableOID}, i)
+			}
+		}
+		return getMatches(idx.qualified, qualifiedName{field.name, field.tableOID})
+	default:
+		return getMatches(idx.exact, field.name)
+	}
+}
+
+func getMatches[K comparable](m map[K]colMatches, key K) colMatches {
+	if c, ok := m[key]; ok {
+		return c
+	}
+	return noColMatches
+}
+
+func addMatch[K comparable](m map[K]colMatches, key K, i int) {
+	m[key] = getMatches(m, key).add(i)
+}
+
+// foldName returns the case-folded form of s, so that names can be compared case-insensitively
+// by their folded forms. Unlike strings.EqualFold, it doesn't account for the few runes whose
+// upper and lower cases don't round-trip, which don't occur in practice in column names.
+func foldName(s string) string {
+	return strings.ToLower(strings.ToUpper(s))
+}
@@ -0,0 +1,89 @@
+package pgx_collect
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Converter converts values scanned into an intermediate type into another type.
+type Converter struct {
+	from reflect.Type
+	// convert converts *src, a pointer to a from, into *dst.
+	convert func(src, dst any) error
+}
+
+var (
+	// Map from reflect.Type of the converted type -> *Converter
+	converters sync.Map
+	// hasConverters is true if any converters are registered, to skip lookups otherwise.
+	hasConverters atomic.Bool
+)
+
+// RegisterFieldConverter registers a Converter which converts values of type from into values of
+// type to, replacing any existing Converter into to. convert is called with a pointer to the
+// scanned from and a pointer to the destination to.
+func RegisterFieldConverter(from, to reflect.Type, convert func(src, dst any) error) {
+	converters.Store(to, &Converter{from: from, convert: convert})
+	hasConverters.Store(true)
+}
+
+// LookupConverter returns the Converter into type to, if one is registered. If to is a pointer
+// without a registered Converter, the Converter into its element type is used, scanning NULL as
+// nil.
+func LookupConverter(to reflect.Type) (*Converter, bool) {
+	if !hasConverters.Load() {
+		return nil, false
+	}
+	if c, ok := converters.Load(to); ok {
+		return c.(*Converter), true
+	}
+	if to.Kind() == reflect.Pointer {
+		if c, ok := converters.Load(to.Elem()); ok {
+			return c.(*Converter).pointerTo(to), true
+		}
+	}
+	return nil, false
+}
+
+// pointerTo returns a Converter into to, a pointer to the type c converts into. It converts from
+// a pointer to c's source type, which is nil when the column is NULL.
+func (c *Converter) pointerTo(to reflect.Type) *Converter {
+	elem := to.Elem()
+	return &Converter{
+		from: reflect.PointerTo(c.from),
+		convert: func(src, dst any) error {
+			srcPtr := reflect.ValueOf(src).Elem()
+			dstPtr := reflect.ValueOf(dst).Elem()
+			if srcPtr.IsNil() {
+				dstPtr.SetZero()
+				return nil
+			}
+			v := reflect.New(elem)
+			if err := c.convert(srcPtr.Interface(), v.Interface()); err != nil {
+				return err
+			}
+			dstPtr.Set(v)
+			return nil
+		},
+	}
+}
+
+// NewSource returns a pointer to a new value of the type the Converter converts from.
+func (c *Converter) NewSource() any {
+	return reflect.New(c.from).Interface()
+}
+
+// Convert converts *src, which must be from NewSource, into *dst.
+func (c *Converter) Convert(src, dst any) error {
+	return c.convert(src, dst)
+}
+
+// convertTarget is the scan target of a field with a Converter.
+type convertTarget struct {
+	converter *Converter
+	// src is the intermediate value the column is scanned into.
+	src any
+	// dst is a pointer to the field for the current row, or nil if it isn't scanned.
+	dst any
+}
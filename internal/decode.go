@@ -0,0 +1,175 @@
+package pgx_collect
+
+import (
+	"database/sql"
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// decoder is a set of ways a field can be decoded from the bytes of its column, rather than being
+// scanned by pgx directly.
+type decoder uint8
+
+const (
+	// decoderJSON decodes the column as JSON, for fields tagged "json".
+	decoderJSON decoder = 1 << iota
+	// decoderText decodes the column with encoding.TextUnmarshaler.
+	decoderText
+	// decoderBinary decodes bytea columns with encoding.BinaryUnmarshaler.
+	decoderBinary
+)
+
+var (
+	sqlScannerType        = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+	pgtypePkgPath         = reflect.TypeOf(pgtype.Text{}).PkgPath()
+	pgtypeMap             = newPgtypeMap()
+)
+
+func newPgtypeMap() *pgtype.Map {
+	m := pgtype.NewMap()
+	// TypeForValue lazily builds an index on first use, which isn't safe to do concurrently.
+	m.TypeForValue(nil)
+	return m
+}
+
+// unmarshalerDecoder returns how a field of type typ can be decoded with encoding.TextUnmarshaler
+// or encoding.BinaryUnmarshaler, for columns of the corresponding types. Types which pgx can scan into directly, either natively or
+// because they implement sql.Scanner or a pgtype scanner interface, are left to pgx.
+// If typ is a pointer, it is allocated when the column is non-NULL, and set to nil otherwise.
+func unmarshalerDecoder(typ reflect.Type) decoder {
+	elem := typ
+	if typ.Kind() == reflect.Pointer {
+		elem = typ.Elem()
+	}
+	ptr := reflect.PointerTo(elem)
+	var d decoder
+	if ptr.Implements(textUnmarshalerType) {
+		d |= decoderText
+	}
+	if ptr.Implements(binaryUnmarshalerType) {
+		d |= decoderBinary
+	}
+	if d == 0 || ptr.Implements(sqlScannerType) || implementsPgtypeScanner(ptr) {
+		return 0
+	}
+	if _, ok := pgtypeMap.TypeForValue(reflect.Zero(elem).Interface()); ok {
+		return 0
+	}
+	return d
+}
+
+// implementsPgtypeScanner returns true if typ implements one of the pgtype scanner interfaces,
+// such as pgtype.TextScanner. These all have a single method, named Scan..., which takes a
+// value from package pgtype.
+func implementsPgtypeScanner(typ reflect.Type) bool {
+	for i := 0; i < typ.NumMethod(); i++ {
+		m := typ.Method(i)
+		if strings.HasPrefix(m.Name, "Scan") &&
+			m.Type.NumIn() == 2 &&
+			m.Type.In(1).PkgPath() == pgtypePkgPath {
+			return true
+		}
+	}
+	return false
+}
+
+// newDecodeTarget returns the scan target for a field decoded by d from the column described by
+// fldDesc, or nil if the column should be scanned by pgx directly. Only text columns are decoded
+// with UnmarshalText, and only bytea columns with UnmarshalBinary.
+func newDecodeTarget(
+	d decoder,
+	typ reflect.Type,
+	fldDesc *pgconn.FieldDescription,
+	jsonUnmarshal func(data []byte, v any) error,
+) *decodeTarget {
+	t := &decodeTarget{
+		nullable: typ.Kind() == reflect.Pointer,
+	}
+	switch {
+	case d&decoderJSON != 0:
+		t.decode = jsonUnmarshal
+		t.nullable = true
+	case d&decoderBinary != 0 && fldDesc.DataTypeOID == pgtype.ByteaOID:
+		t.decode = unmarshalBinary
+	case d&decoderText != 0 && isTextOID(fldDesc.DataTypeOID):
+		t.decode = unmarshalText
+	default:
+		return nil
+	}
+	return t
+}
+
+// isTextOID returns true if oid is the OID of a text type.
+func isTextOID(oid uint32) bool {
+	switch oid {
+	case pgtype.TextOID, pgtype.VarcharOID, pgtype.BPCharOID, pgtype.NameOID, pgtype.UnknownOID:
+		return true
+	default:
+		return false
+	}
+}
+
+// decodeTarget is the scan target of a field which is decoded from the bytes of its column. The
+// bytes are copied into a buffer reused between rows.
+type decodeTarget struct {
+	decode func(data []byte, dst any) error
+	// nullable is true if NULL is scanned as the zero value, rather than being an error.
+	nullable bool
+	// dst is a pointer to the field for the current row.
+	dst any
+	buf []byte
+}
+
+// ScanBytes implements pgtype.BytesScanner.
+func (t *decodeTarget) ScanBytes(src []byte) error {
+	if src == nil {
+		return t.scanNull()
+	}
+	t.buf = append(t.buf[:0], src...)
+	return t.decode(t.buf, t.dst)
+}
+
+// ScanText implements pgtype.TextScanner.
+func (t *decodeTarget) ScanText(v pgtype.Text) error {
+	if !v.Valid {
+		return t.scanNull()
+	}
+	t.buf = append(t.buf[:0], v.String...)
+	return t.decode(t.buf, t.dst)
+}
+
+func (t *decodeTarget) scanNull() error {
+	v := reflect.ValueOf(t.dst).Elem()
+	if !t.nullable {
+		return fmt.Errorf("cannot scan NULL into %s", v.Type())
+	}
+	v.SetZero()
+	return nil
+}
+
+func unmarshalText(data []byte, dst any) error {
+	return unmarshaler[encoding.TextUnmarshaler](dst).UnmarshalText(data)
+}
+
+func unmarshalBinary(data []byte, dst any) error {
+	return unmarshaler[encoding.BinaryUnmarshaler](dst).UnmarshalBinary(data)
+}
+
+// unmarshaler returns dst as a U, where dst is either a U or a pointer to a (possibly nil) U.
+func unmarshaler[U any](dst any) U {
+	if u, ok := dst.(U); ok {
+		return u
+	}
+	v := reflect.ValueOf(dst).Elem()
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	return v.Interface().(U)
+}
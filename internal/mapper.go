@@ -0,0 +1,79 @@
+package pgx_collect
+
+import (
+	"strings"
+	"sync"
+)
+
+// MapperConfig configures how a FieldMapper maps struct fields to columns.
+type MapperConfig struct {
+	// ColumnName converts the name of a field without a tag into the name of the column it
+	// matches exactly. If ColumnName is nil, fields match columns case-insensitively,
+	// ignoring underscores.
+	ColumnName func(fieldName string) string
+	// TagKeys contains the keys of the struct tags which configure how fields are mapped,
+	// in order of precedence. If TagKeys is empty, the "db" tag is used.
+	TagKeys []string
+	// Tables maps table names to OIDs. Tagged fields may be qualified with a table name in
+	// Tables, e.g. `db:"users.id"`, to match only the column of that table.
+	Tables map[string]uint32
+	// UnsafeFieldAccess constructs pointers to fields with unsafe.Pointer arithmetic rather than
+	// reflection when scanning rows.
+	UnsafeFieldAccess bool
+	// NullZero scans NULL into all fields as their zero value, as if they were tagged "nullzero".
+	NullZero bool
+	// JSONUnmarshal decodes columns into fields tagged "json". If JSONUnmarshal is nil,
+	// json.Unmarshal is used.
+	JSONUnmarshal func(data []byte, v any) error
+	// Setters maps columns to methods of pointers to structs named Set<Name>, which take a single
+	// argument and optionally return an error, as if they were fields named <Name>. This only
+	// applies when matching columns by name, and if there isn't a field named <Name>.
+	Setters bool
+	// DiscardExtraColumns makes the positional scanners discard the columns after those mapped to
+	// the struct's fields, rather than failing.
+	DiscardExtraColumns bool
+}
+
+// FieldMapper maps struct fields to columns, caching the results. Since caches are not shared
+// between FieldMappers, a FieldMapper should be created once and reused.
+type FieldMapper struct {
+	config MapperConfig
+
+	// Map from reflect.Type -> *structTypeInfo
+	structTypeInfoMap sync.Map
+	// Map from reflect.Type -> *structRowFieldsByPosEntry
+	structRowFieldsByPosMap sync.Map
+	// Map from structRowFieldsByNameKey -> *[]structRowFieldsByNameEntry
+	// See GetStructRowFieldsByName for details.
+	structRowFieldsByNameMap sync.Map
+}
+
+// DefaultFieldMapper is the FieldMapper used by the struct scanners unless another is provided.
+var DefaultFieldMapper = NewFieldMapper(MapperConfig{})
+
+// NewFieldMapper returns a FieldMapper with the given config.
+func NewFieldMapper(config MapperConfig) *FieldMapper {
+	return &FieldMapper{config: config}
+}
+
+// fieldName returns the name a field matches columns with, given its name and the prefix of its
+// enclosing struct.
+func (m *FieldMapper) fieldName(prefix string, name string) fieldName {
+	if m.config.ColumnName == nil {
+		return fieldName{
+			name:       foldName(strings.ReplaceAll(prefix+name, "_", "")),
+			exactMatch: false,
+		}
+	}
+	return fieldName{
+		name:       prefix + m.config.ColumnName(name),
+		exactMatch: true,
+	}
+}
+
+// clearCaches clears all caches of the FieldMapper.
+func (m *FieldMapper) clearCaches() {
+	m.structTypeInfoMap = sync.Map{}
+	m.structRowFieldsByPosMap = sync.Map{}
+	m.structRowFieldsByNameMap = sync.Map{}
+}
@@ -0,0 +1,413 @@
+package pgx_collect
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// selfScannerType must have the same method set as pgx_collect.SelfScanner.
+var selfScannerType = reflect.TypeOf((*selfScanner)(nil)).Elem()
+
+type selfScanner interface {
+	InitializeScan(fldDescs []pgconn.FieldDescription) error
+	ScanRowFrom(rows pgx.Rows) error
+}
+
+// selfScannerColumns must have the same method set as pgx_collect.SelfScannerColumns, less
+// that of selfScanner.
+type selfScannerColumns interface {
+	ScannedColumns() []string
+}
+
+// StructRowFields describes how to scan the columns of a row into the fields of a struct.
+type StructRowFields struct {
+	// fields contains the field corresponding to each column, in column order.
+	fields []structRowField
+	// selfScanners contains the fields which scan themselves from the whole row.
+	selfScanners []structRowField
+	// groups contains the pointer groups of the struct, parents before children.
+	// The first group is the outermost struct itself.
+	groups []rowGroup
+	// rest is the field which receives the columns in restCols, if any.
+	rest     structRowField
+	restCols []int
+	// unsafeFieldAccess is true if pointers to fields are constructed with unsafe.Pointer
+	// arithmetic rather than reflection.
+	unsafeFieldAccess bool
+	// nullZero is true if any of the fields scan NULL as their zero value.
+	nullZero bool
+	// setters contains the setter methods mapped to columns.
+	setters []setterCol
+	// decode is true if any of the fields are decoded from the bytes of their columns.
+	decode bool
+	// jsonUnmarshal decodes the columns of json fields.
+	jsonUnmarshal func(data []byte, v any) error
+}
+
+// setterCol is a setter method mapped to a column.
+type setterCol struct {
+	col    int
+	setter *setterField
+}
+
+// rowGroup is a ptrGroup, along with the columns mapped to fields inside of it.
+type rowGroup struct {
+	ptrGroup
+	// cols contains the columns mapped to fields in this group or any group inside of it.
+	cols []int
+}
+
+// newStructRowFields returns the StructRowFields for a struct with the given type info,
+// where fields contains the field corresponding to each column, restCols contains the columns
+// collected by the rest field, and setters contains the setter methods mapped to columns.
+func newStructRowFields(
+	info *structTypeInfo,
+	fields []structRowField,
+	restCols []int,
+	setters []setterCol,
+) StructRowFields {
+	nullZero, decode := false, false
+	for _, f := range fields {
+		nullZero = nullZero || f.nullZero
+		decode = decode || f.decoder != 0
+	}
+	var groups []rowGroup
+	if len(info.groups) > 1 {
+		groups = make([]rowGroup, len(info.groups))
+		for g := range info.groups {
+			groups[g].ptrGroup = info.groups[g]
+		}
+		for i, f := range fields {
+			if !f.isSet() {
+				continue
+			}
+			for g := f.group; g > 0; g = groups[g].parent {
+				groups[g].cols = append(groups[g].cols, i)
+			}
+		}
+	}
+	return StructRowFields{
+		fields:       fields,
+		selfScanners: info.selfScanners,
+		groups:       groups,
+		rest:         info.rest,
+		restCols:     restCols,
+
+		unsafeFieldAccess: info.unsafeFieldAccess,
+		nullZero:          nullZero,
+		decode:            decode,
+		jsonUnmarshal:     info.jsonUnmarshal,
+		setters:           setters,
+	}
+}
+
+func (fs StructRowFields) NumFields() int {
+	return len(fs.fields)
+}
+
+// ScanState holds the per-query state used to scan rows into a struct.
+type ScanState struct {
+	selfScanners []selfScanField
+	// present records whether each pointer group is non-nil for the current row.
+	present []bool
+	// bases contains a pointer to the struct of each pointer group for the current row, when
+	// using unsafe field access.
+	bases []unsafe.Pointer
+	// decodeTargets contains the scan target for each column of a decoded field, and nil
+	// otherwise.
+	decodeTargets []*decodeTarget
+	// convertTargets contains the scan target for each column of a field with a Converter, and
+	// nil otherwise.
+	convertTargets []*convertTarget
+	// restNames and restValues contain the name of each column collected by the rest field,
+	// and a pointer to the value it is scanned into.
+	restNames  []string
+	restValues []any
+	// setterValues contains a pointer to the value each setter method's column is scanned into.
+	setterValues []reflect.Value
+}
+
+type selfScanField struct {
+	field structRowField
+	// proto is the value that InitializeScan was called on.
+	proto reflect.Value
+}
+
+// NewScanState returns the state used to scan rows with the given field descriptions.
+// This includes initializing the fields of the struct which scan themselves.
+func (fs StructRowFields) NewScanState(fldDescs []pgconn.FieldDescription) (ScanState, error) {
+	var state ScanState
+	if len(fs.groups) > 0 {
+		state.present = make([]bool, len(fs.groups))
+		if fs.unsafeFieldAccess {
+			state.bases = make([]unsafe.Pointer, len(fs.groups))
+		}
+	}
+	if len(fs.selfScanners) > 0 {
+		state.selfScanners = make([]selfScanField, len(fs.selfScanners))
+		for i, f := range fs.selfScanners {
+			proto := reflect.New(f.typ)
+			if err := proto.Interface().(selfScanner).InitializeScan(fldDescs); err != nil {
+				return ScanState{}, err
+			}
+			state.selfScanners[i] = selfScanField{field: f, proto: proto.Elem()}
+		}
+	}
+	for i, f := range fs.fields {
+		if !f.isSet() || f.decoder&decoderJSON != 0 {
+			continue
+		}
+		// Converters are looked up for each query, so they apply even if they are registered
+		// after the struct type is first used.
+		if c, ok := LookupConverter(f.typ); ok {
+			if state.convertTargets == nil {
+				state.convertTargets = make([]*convertTarget, len(fs.fields))
+			}
+			state.convertTargets[i] = &convertTarget{converter: c, src: c.NewSource()}
+		}
+	}
+	if fs.decode {
+		state.decodeTargets = make([]*decodeTarget, len(fs.fields))
+		for i, f := range fs.fields {
+			if f.decoder != 0 && (state.convertTargets == nil || state.convertTargets[i] == nil) {
+				state.decodeTargets[i] = newDecodeTarget(f.decoder, f.typ, &fldDescs[i], fs.jsonUnmarshal)
+			}
+		}
+	}
+	if len(fs.setters) > 0 {
+		state.setterValues = make([]reflect.Value, len(fs.setters))
+		for i, s := range fs.setters {
+			state.setterValues[i] = reflect.New(s.setter.typ)
+		}
+	}
+	if len(fs.restCols) > 0 {
+		state.restNames = make([]string, len(fs.restCols))
+		state.restValues = make([]any, len(fs.restCols))
+		isText := fs.rest.typ.Elem() == stringType
+		for i, col := range fs.restCols {
+			state.restNames[i] = fldDescs[col].Name
+			if isText {
+				state.restValues[i] = new(pgtype.Text)
+			} else {
+				state.restValues[i] = new(any)
+			}
+		}
+	}
+	return state, nil
+}
+
+// Populate fills scanTargets with pointers to the fields of r for the current row. Columns
+// without a corresponding field get a nil target, which causes rows.Scan to skip them.
+//
+// Pointer groups are allocated if any of their columns are non-NULL, and set to nil otherwise.
+// Pointer groups without any columns are left unchanged.
+// Columns inside nil groups are skipped. NULL columns are also skipped for nullzero fields,
+// which are set to their zero value instead.
+func (fs StructRowFields) Populate(
+	r StructRowFieldReceiver,
+	rows pgx.Rows,
+	state *ScanState,
+	scanTargets []any,
+) {
+	var rawValues [][]byte
+	if len(fs.groups) > 0 || fs.nullZero {
+		rawValues = rows.RawValues()
+	}
+	if len(fs.groups) > 0 {
+		fs.populateGroups(r, rawValues, state)
+	}
+	if fs.unsafeFieldAccess {
+		fs.populateUnsafe(r, state, scanTargets)
+	} else {
+		for i, f := range fs.fields {
+			if f.isSet() && (f.group == 0 || state.present[f.group]) {
+				scanTargets[i] = r.getField(f)
+			} else {
+				scanTargets[i] = nil
+			}
+		}
+	}
+	if fs.nullZero {
+		fs.populateNullZero(r, rawValues, scanTargets)
+	}
+	if fs.decode {
+		for i, t := range state.decodeTargets {
+			if t != nil && scanTargets[i] != nil {
+				t.dst = scanTargets[i]
+				scanTargets[i] = t
+			}
+		}
+	}
+	for i, t := range state.convertTargets {
+		if t != nil {
+			t.dst = scanTargets[i]
+			if t.dst != nil {
+				scanTargets[i] = t.src
+			}
+		}
+	}
+	for i, s := range fs.setters {
+		scanTargets[s.col] = state.setterValues[i].Interface()
+	}
+	if len(fs.restCols) > 0 && (fs.rest.group == 0 || state.present[fs.rest.group]) {
+		for i, col := range fs.restCols {
+			scanTargets[col] = state.restValues[i]
+		}
+	}
+}
+
+// populateNullZero zeroes the nullzero fields with NULL columns, and skips scanning them.
+func (fs StructRowFields) populateNullZero(
+	r StructRowFieldReceiver,
+	rawValues [][]byte,
+	scanTargets []any,
+) {
+	for i := range fs.fields {
+		f := &fs.fields[i]
+		if f.nullZero && rawValues[i] == nil && scanTargets[i] != nil {
+			scanTargets[i] = nil
+			reflect.Value(r).FieldByIndex(f.path).SetZero()
+		}
+	}
+}
+
+// populateUnsafe is the equivalent of the loop in Populate using unsafe field access.
+func (fs StructRowFields) populateUnsafe(
+	r StructRowFieldReceiver,
+	state *ScanState,
+	scanTargets []any,
+) {
+	base := reflect.Value(r).Addr().UnsafePointer()
+	for i := range fs.fields {
+		f := &fs.fields[i]
+		if !f.isSet() {
+			scanTargets[i] = nil
+		} else if f.group == 0 {
+			scanTargets[i] = getFieldUnsafe(base, f)
+		} else if state.present[f.group] {
+			scanTargets[i] = getFieldUnsafe(state.bases[f.group], f)
+		} else {
+			scanTargets[i] = nil
+		}
+	}
+}
+
+func (fs StructRowFields) populateGroups(
+	r StructRowFieldReceiver,
+	rawValues [][]byte,
+	state *ScanState,
+) {
+	present := state.present
+	present[0] = true
+	for g := 1; g < len(fs.groups); g++ {
+		grp := &fs.groups[g]
+		if !present[grp.parent] || len(grp.cols) == 0 {
+			// Groups without any columns are left unchanged, so scanning a subset of the
+			// columns into an existing value doesn't clear them.
+			present[g] = false
+			continue
+		}
+		present[g] = anyNonNull(rawValues, grp.cols)
+		ptr := reflect.Value(r).FieldByIndex(grp.field.path)
+		if !present[g] {
+			ptr.Set(reflect.Zero(grp.field.typ))
+		} else if ptr.IsNil() {
+			ptr.Set(reflect.New(grp.field.typ.Elem()))
+		}
+		if state.bases != nil && present[g] {
+			state.bases[g] = ptr.UnsafePointer()
+		}
+	}
+}
+
+func anyNonNull(rawValues [][]byte, cols []int) bool {
+	for _, i := range cols {
+		if rawValues[i] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Complete finishes scanning the current row into r, after rows.Scan has been called with the
+// targets from Populate. This converts the fields with Converters, calls the setter methods,
+// scans the fields of r which scan themselves, and collects the columns of the rest field into a
+// new map. NULL columns are omitted from map[string]string.
+func (fs StructRowFields) Complete(r StructRowFieldReceiver, rows pgx.Rows, state *ScanState) error {
+	for i, t := range state.convertTargets {
+		if t != nil && t.dst != nil {
+			if err := t.converter.Convert(t.src, t.dst); err != nil {
+				return fmt.Errorf("can't convert into dest[%d]: %w", i, err)
+			}
+		}
+	}
+	if len(fs.setters) > 0 {
+		if err := fs.completeSetters(r, state); err != nil {
+			return err
+		}
+	}
+	if len(fs.restCols) > 0 && (fs.rest.group == 0 || state.present[fs.rest.group]) {
+		fs.completeRest(r, state)
+	}
+	for _, s := range state.selfScanners {
+		if s.field.group != 0 && !state.present[s.field.group] {
+			continue
+		}
+		v := reflect.Value(r).FieldByIndex(s.field.path)
+		v.Set(s.proto)
+		if err := v.Addr().Interface().(selfScanner).ScanRowFrom(rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs StructRowFields) completeRest(r StructRowFieldReceiver, state *ScanState) {
+	v := reflect.Value(r).FieldByIndex(fs.rest.path)
+	switch m := v.Addr().Interface().(type) {
+	case *map[string]any:
+		*m = make(map[string]any, len(state.restValues))
+		for i, val := range state.restValues {
+			(*m)[state.restNames[i]] = *val.(*any)
+		}
+	case *map[string]string:
+		*m = make(map[string]string, len(state.restValues))
+		for i, val := range state.restValues {
+			if text := val.(*pgtype.Text); text.Valid {
+				(*m)[state.restNames[i]] = text.String
+			}
+		}
+	default:
+		// A named map type.
+		rm := reflect.MakeMapWithSize(v.Type(), len(state.restValues))
+		for i, val := range state.restValues {
+			if text, ok := val.(*pgtype.Text); ok {
+				if text.Valid {
+					rm.SetMapIndex(reflect.ValueOf(state.restNames[i]), reflect.ValueOf(text.String))
+				}
+			} else {
+				elem := reflect.ValueOf(val).Elem()
+				rm.SetMapIndex(reflect.ValueOf(state.restNames[i]), elem)
+			}
+		}
+		v.Set(rm)
+	}
+}
+
+func (fs StructRowFields) completeSetters(r StructRowFieldReceiver, state *ScanState) error {
+	ptr := reflect.Value(r).Addr()
+	var arg [1]reflect.Value
+	for i, s := range fs.setters {
+		arg[0] = state.setterValues[i].Elem()
+		out := ptr.Method(s.setter.method).Call(arg[:])
+		if s.setter.returnsErr && !out[0].IsNil() {
+			return fmt.Errorf("%s: %w", s.setter.methodName, out[0].Interface().(error))
+		}
+	}
+	return nil
+}
@@ -1,9 +1,10 @@
 package pgx_collect
 
 import (
+	"encoding/json"
 	"fmt"
-	"hash/fnv"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"unsafe"
@@ -11,27 +12,24 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
-const structTagKey = "db"
-
-type StructRowFields []structRowField
-
-func (fs StructRowFields) NumFields() int {
-	return len(fs)
-}
-
-func (fs StructRowFields) Populate(r StructRowFieldReceiver, scanTargets []any) {
-	for i, f := range fs {
-		scanTargets[i] = r.getField(f)
-	}
-}
+// defaultStructTagKey is the struct tag key used when MapperConfig.TagKeys is empty.
+const defaultStructTagKey = "db"
 
 // structRowField describes a field of a struct.
 type structRowField struct {
-	// TODO: It would be a bit more efficient to track the path using the pointer
-	// offset within the (outermost) struct and use unsafe.Pointer arithmetic to
-	// construct references when scanning rows. However, it's not clear it's worth
-	// using unsafe for this.
 	path []int
+	typ  reflect.Type
+	// group is the index of the pointer group containing the field in structTypeInfo.groups.
+	group int
+	// offset is the byte offset of the field within the struct of its group. It's used to
+	// construct pointers to the field with unsafe.Pointer arithmetic rather than by traversing
+	// path. It's only set for fields mapped to columns.
+	offset uintptr
+	// nullZero is true if NULL is scanned into the field as its zero value.
+	nullZero bool
+	// decoder is how the field is decoded from the bytes of its column, if it isn't scanned by
+	// pgx directly.
+	decoder decoder
 }
 
 func (f structRowField) isSet() bool {
@@ -48,106 +46,546 @@ func (r StructRowFieldReceiver) getField(f structRowField) any {
 	return reflect.Value(r).FieldByIndex(f.path).Addr().Interface()
 }
 
+// getFieldUnsafe is equivalent to getField, given a pointer to the struct of f's group.
+func getFieldUnsafe(base unsafe.Pointer, f *structRowField) any {
+	// Converting a pointer Value to an interface doesn't allocate.
+	return reflect.NewAt(f.typ, unsafe.Add(base, f.offset)).Interface()
+}
+
 type fieldName struct {
 	name       string
 	exactMatch bool
+	// tableOID is the OID of the table the column must belong to, or 0 if it can belong to any.
+	// It is only set for exact matches.
+	tableOID uint32
 }
 
 type namedStructRowField struct {
 	field structRowField
 	fieldName
+	requirement requirement
+	// pos is the position of the field's column for the positional scanners, or -1 if the field
+	// isn't tagged with one.
+	pos int
+	// promoted is true if the field is a field of the outermost struct, or is promoted from
+	// structs embedded without a prefix. Only promoted fields shadow each other.
+	promoted bool
+}
+
+// requirement is whether a field must have a corresponding column.
+type requirement int8
+
+const (
+	// requirementDefault fields must have a corresponding column unless the scanner is lax.
+	requirementDefault requirement = iota
+	// requirementOptional fields never need a corresponding column.
+	requirementOptional
+	// requirementRequired fields always need a corresponding column.
+	requirementRequired
+)
+
+// structTypeInfo describes the fields of a struct type, independent of any particular row.
+type structTypeInfo struct {
+	fields       []namedStructRowField
+	selfScanners []structRowField
+	// selfScannedCols contains the columns which the fields that scan themselves declare that
+	// they read, so aren't unmatched.
+	selfScannedCols map[string]bool
+	// groups contains the (embedded) pointers to structs whose fields are mapped to columns.
+	// The first group is the outermost struct itself.
+	groups []ptrGroup
+	// rest is the field which receives the columns not mapped to any other field, if any.
+	rest structRowField
+	// err is the error, if any, which makes the struct type unusable.
+	err error
+	// unsafeFieldAccess is true if pointers to fields are constructed with unsafe.Pointer
+	// arithmetic rather than reflection.
+	unsafeFieldAccess bool
+	// jsonUnmarshal decodes the columns of json fields.
+	jsonUnmarshal func(data []byte, v any) error
+	// setters contains the setter methods of the struct which are mapped to columns.
+	setters []setterField
+	// byPos contains the indexes of fields in the order of their tagged positions, or nil if
+	// the fields aren't tagged with positions, in which case they're in declaration order.
+	byPos []int
+	// posErr is the error, if any, which makes the struct type unusable by the positional
+	// scanners.
+	posErr error
+}
+
+// setterField describes a method of a pointer to a struct which sets the value of a column,
+// e.g. SetEmail(string) error. It is mapped to columns like a field named Email.
+type setterField struct {
+	fieldName
+	methodName string
+	// method is the index of the method in the pointer type's method set.
+	method int
+	// typ is the type of the method's argument.
+	typ reflect.Type
+	// returnsErr is true if the method returns an error.
+	returnsErr bool
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// computeSetters returns the setter methods of a pointer to t which aren't shadowed by one of
+// fields. A method Set<Name> is shadowed by a field of t, or one promoted from an embedded struct,
+// with the Go name <Name>, regardless of the column the field is mapped to. The fields of nested
+// structs which aren't promoted, e.g. prefixed structs, don't shadow setters.
+func (m *FieldMapper) computeSetters(t reflect.Type, fields []namedStructRowField) []setterField {
+	fieldNames := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if !f.promoted {
+			continue
+		}
+		path := structFieldPathName(t, f.field.path)
+		fieldNames[path[strings.LastIndexByte(path, '.')+1:]] = true
+	}
+	var setters []setterField
+	ptr := reflect.PointerTo(t)
+	for i := 0; i < ptr.NumMethod(); i++ {
+		method := ptr.Method(i)
+		name, ok := strings.CutPrefix(method.Name, "Set")
+		if !ok || name == "" || method.Type.NumIn() != 2 {
+			continue
+		}
+		returnsErr := false
+		switch method.Type.NumOut() {
+		case 0:
+		case 1:
+			if method.Type.Out(0) != errorType {
+				continue
+			}
+			returnsErr = true
+		default:
+			continue
+		}
+		setter := setterField{
+			fieldName:  m.fieldName("", name),
+			methodName: method.Name,
+			method:     i,
+			typ:        method.Type.In(1),
+			returnsErr: returnsErr,
+		}
+		if !fieldNames[name] {
+			setters = append(setters, setter)
+		}
+	}
+	return setters
 }
 
-var namedStructRowFieldMap sync.Map
+// ptrGroup describes a pointer to a struct whose fields are mapped to columns. The pointer is
+// left nil when all of those columns are NULL, and allocated otherwise.
+type ptrGroup struct {
+	// field is the pointer field. It is unset for the outermost struct.
+	field structRowField
+	// parent is the index of the group containing this group, or -1 for the outermost struct.
+	parent int
+}
 
-func lookupNamedStructRowFields(t reflect.Type) []namedStructRowField {
-	if resultIface, ok := namedStructRowFieldMap.Load(t); ok {
-		return resultIface.([]namedStructRowField)
+func (m *FieldMapper) lookupStructTypeInfo(t reflect.Type) *structTypeInfo {
+	if resultIface, ok := m.structTypeInfoMap.Load(t); ok {
+		return resultIface.(*structTypeInfo)
 	}
-	result := computeStructFieldNames(t)
-	resultIface, _ := namedStructRowFieldMap.LoadOrStore(t, result)
-	return resultIface.([]namedStructRowField)
+	result := m.computeStructFieldNames(t)
+	resultIface, _ := m.structTypeInfoMap.LoadOrStore(t, result)
+	return resultIface.(*structTypeInfo)
 }
 
-func computeStructFieldNames(t reflect.Type) []namedStructRowField {
-	fields := make([]namedStructRowField, 0, t.NumField())
-	fieldStack := make([]int, 0, 1)
+func (m *FieldMapper) computeStructFieldNames(t reflect.Type) *structTypeInfo {
+	info := &structTypeInfo{
+		fields: make([]namedStructRowField, 0, t.NumField()),
+		groups: []ptrGroup{{parent: -1}},
 
-	var helper func(t reflect.Type)
-	helper = func(t reflect.Type) {
+		unsafeFieldAccess: m.config.UnsafeFieldAccess,
+		jsonUnmarshal:     m.config.JSONUnmarshal,
+	}
+	if info.jsonUnmarshal == nil {
+		info.jsonUnmarshal = json.Unmarshal
+	}
+	fieldStack := make([]int, 0, 1)
+	// groupTypes contains the struct types of the pointer groups currently being visited,
+	// to avoid infinite recursion on recursive types.
+	groupTypes := []reflect.Type{t}
+
+	// prefix is prepended to the column names of all fields of t.
+	// group is the index of the pointer group containing the fields of t.
+	// offset is the byte offset of t within the struct of the group.
+	// promoted is true if the fields of t are promoted fields of the outermost struct.
+	var helper func(t reflect.Type, prefix string, group int, offset uintptr, promoted bool)
+	helper = func(t reflect.Type, prefix string, group int, offset uintptr, promoted bool) {
 		tail := len(fieldStack)
 		fieldStack = append(fieldStack, 0)
 		for i := 0; i < t.NumField(); i++ {
 			sf := t.Field(i)
 			fieldStack[tail] = i
-			// Handle anonymous struct embedding, but do not try to handle embedded pointers.
-			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
-				helper(sf.Type)
-			} else if field, ok := makeNamedStructRowField(sf, fieldStack); ok {
-				fields = append(fields, field)
+			tag := m.parseStructTag(sf)
+			embedded := promoted && sf.Anonymous && !tag.hasPrefix
+			if isSelfScanningField(sf, tag) {
+				info.selfScanners = append(info.selfScanners, structRowField{
+					path:  append([]int(nil), fieldStack...),
+					typ:   sf.Type,
+					group: group,
+				})
+				if c, ok := reflect.New(sf.Type).Interface().(selfScannerColumns); ok {
+					if info.selfScannedCols == nil {
+						info.selfScannedCols = make(map[string]bool)
+					}
+					for _, col := range c.ScannedColumns() {
+						info.selfScannedCols[col] = true
+					}
+				}
+			} else if tag.rest {
+				if info.err != nil {
+					continue
+				}
+				if sf.PkgPath != "" || !isRestFieldType(sf.Type) {
+					info.err = fmt.Errorf(
+						"rest field %s must be an exported map[string]any or map[string]string",
+						sf.Name,
+					)
+				} else if info.rest.isSet() {
+					info.err = fmt.Errorf("struct has multiple rest fields")
+				} else {
+					info.rest = structRowField{
+						path:  append([]int(nil), fieldStack...),
+						typ:   sf.Type,
+						group: group,
+					}
+				}
+			} else if (tag.hasPrefix || tag.group) && sf.Type.Kind() == reflect.Struct {
+				if sf.PkgPath == "" || sf.Anonymous {
+					helper(sf.Type, prefix+tag.prefix, group, offset+sf.Offset, embedded)
+				}
+			} else if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				helper(sf.Type, prefix, group, offset+sf.Offset, promoted)
+			} else if (sf.Anonymous || tag.hasPrefix || tag.group) &&
+				isStructPointerGroup(sf, groupTypes) {
+				// Pointers to structs are handled like structs, except that the pointer is
+				// nil if all the columns mapped to its fields are NULL.
+				info.groups = append(info.groups, ptrGroup{
+					field: structRowField{
+						path:  append([]int(nil), fieldStack...),
+						typ:   sf.Type,
+						group: group,
+					},
+					parent: group,
+				})
+				groupTypes = append(groupTypes, sf.Type.Elem())
+				helper(sf.Type.Elem(), prefix+tag.prefix, len(info.groups)-1, 0, embedded)
+				groupTypes = groupTypes[:len(groupTypes)-1]
+			} else if field, ok := m.makeNamedStructRowField(sf, tag, prefix, fieldStack); ok {
+				field.field.group = group
+				field.field.offset = offset + sf.Offset
+				field.field.nullZero = tag.nullZero || m.config.NullZero
+				field.promoted = promoted
+				if tag.json {
+					field.field.decoder = decoderJSON
+				} else {
+					field.field.decoder = unmarshalerDecoder(sf.Type)
+				}
+				info.fields = append(info.fields, field)
 			}
 		}
 		fieldStack = fieldStack[:tail]
 	}
-	helper(t)
-	return fields
+	helper(t, "", 0, 0, true)
+	info.byPos, info.posErr = fieldsByPos(t, info.fields)
+	if m.config.Setters {
+		info.setters = m.computeSetters(t, info.fields)
+	}
+	return info
+}
+
+// fieldsByPos returns the indexes of fields in the order of their tagged positions, or nil if none
+// of the fields are tagged with one. If any are, then all must be, and the positions must be
+// unique and contiguous from 0.
+func fieldsByPos(t reflect.Type, fields []namedStructRowField) ([]int, error) {
+	tagged := false
+	for _, f := range fields {
+		tagged = tagged || f.pos != posUnset
+	}
+	if !tagged {
+		return nil, nil
+	}
+	byPos := make([]int, len(fields))
+	for i := range byPos {
+		byPos[i] = -1
+	}
+	for i, f := range fields {
+		name := structFieldPathName(t, f.field.path)
+		switch {
+		case f.pos == posUnset:
+			return nil, fmt.Errorf("struct field %s has no pos, but other fields do", name)
+		case f.pos == posInvalid:
+			return nil, fmt.Errorf("struct field %s has an invalid pos", name)
+		case f.pos >= len(fields):
+			return nil, fmt.Errorf(
+				"struct field %s has pos %d, but struct has only %d fields",
+				name,
+				f.pos,
+				len(fields),
+			)
+		case byPos[f.pos] != -1:
+			return nil, fmt.Errorf(
+				"struct fields %s and %s both have pos %d",
+				structFieldPathName(t, fields[byPos[f.pos]].field.path),
+				name,
+				f.pos,
+			)
+		}
+		byPos[f.pos] = i
+	}
+	// Since the positions are unique and less than the number of fields, they're contiguous.
+	return byPos, nil
+}
+
+// isStructPointerGroup returns true if sf is a settable pointer to a struct, which isn't
+// already being visited.
+func isStructPointerGroup(sf reflect.StructField, groupTypes []reflect.Type) bool {
+	if sf.PkgPath != "" || sf.Type.Kind() != reflect.Pointer {
+		return false
+	}
+	elem := sf.Type.Elem()
+	if elem.Kind() != reflect.Struct {
+		return false
+	}
+	for _, t := range groupTypes {
+		if t == elem {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	anyType    = reflect.TypeOf((*any)(nil)).Elem()
+	stringType = reflect.TypeOf("")
+)
+
+// isRestFieldType returns true if t can receive the columns not mapped to any other field.
+func isRestFieldType(t reflect.Type) bool {
+	return t.Kind() == reflect.Map &&
+		t.Key() == stringType &&
+		(t.Elem() == anyType || t.Elem() == stringType)
+}
+
+func isSelfScanningField(sf reflect.StructField, tag structTag) bool {
+	if sf.PkgPath != "" || tag.name == "-" {
+		return false
+	}
+	return reflect.PointerTo(sf.Type).Implements(selfScannerType)
+}
+
+// structTag is a parsed struct tag.
+type structTag struct {
+	// name is the column name, or "-" if the field is ignored.
+	name    string
+	present bool
+	// prefix is prepended to the column names of the fields of a nested struct.
+	prefix    string
+	hasPrefix bool
+	// group is true if the fields of a nested struct (or pointer to struct) are mapped to
+	// columns as if they were fields of the outer struct, without a prefix.
+	group bool
+	// rest is true if the field receives the columns not mapped to any other field.
+	rest bool
+	// requirement is whether the field must have a corresponding column.
+	requirement requirement
+	// nullZero is true if NULL is scanned into the field as its zero value.
+	nullZero bool
+	// json is true if the column is decoded into the field as JSON.
+	json bool
+	// pos is the position of the field's column for the positional scanners, posUnset if
+	// the tag has no position, or posInvalid if it isn't a non-negative integer.
+	pos int
+}
+
+const (
+	posUnset   = -1
+	posInvalid = -2
+)
+
+// parseStructTag parses the struct tag of sf. The tag is the first of the mapper's tag keys
+// which is present, and has the form "name,opt1,opt2...".
+// The supported options are:
+//   - prefix: Map the fields of a nested struct (or pointer to struct) to columns prefixed
+//     with "name_".
+//   - prefix=p: Map the fields of a nested struct (or pointer to struct) to columns prefixed
+//     with p.
+//   - group: Map the fields of a nested struct (or pointer to struct) to columns without a
+//     prefix.
+//   - rest: Collect the columns not mapped to any other field into a map.
+//   - optional: The field doesn't need a corresponding column, even if the scanner isn't lax.
+//   - required: The field needs a corresponding column, even if the scanner is lax.
+//   - nullzero: Scan NULL into the field as its zero value.
+//   - json: Decode the column into the field as JSON.
+//   - pos=n: Map the field to the column at position n for the positional scanners.
+func (m *FieldMapper) parseStructTag(sf reflect.StructField) structTag {
+	dbTag, present := m.lookupStructTag(sf)
+	if !present {
+		return structTag{pos: posUnset}
+	}
+	name, opts, _ := strings.Cut(dbTag, ",")
+	tag := structTag{name: name, present: true, pos: posUnset}
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		key, value, hasValue := strings.Cut(opt, "=")
+		switch key {
+		case "prefix":
+			tag.hasPrefix = true
+			if hasValue {
+				tag.prefix = value
+			} else if name != "" {
+				tag.prefix = name + "_"
+			}
+		case "group":
+			tag.group = true
+		case "rest":
+			tag.rest = true
+		case "optional":
+			tag.requirement = requirementOptional
+		case "required":
+			tag.requirement = requirementRequired
+		case "nullzero":
+			tag.nullZero = true
+		case "json":
+			tag.json = true
+		case "pos":
+			tag.pos = posInvalid
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				tag.pos = n
+			}
+		}
+	}
+	return tag
 }
 
-func makeNamedStructRowField(sf reflect.StructField, fieldStack []int) (field namedStructRowField, ok bool) {
+func (m *FieldMapper) lookupStructTag(sf reflect.StructField) (string, bool) {
+	if len(m.config.TagKeys) == 0 {
+		return sf.Tag.Lookup(defaultStructTagKey)
+	}
+	for _, key := range m.config.TagKeys {
+		if tag, ok := sf.Tag.Lookup(key); ok {
+			return tag, true
+		}
+	}
+	return "", false
+}
+
+// taggedFieldName returns the name a field with the given tag name matches columns with.
+// If the mapper has registered tables, the tag name may be qualified with the name of one of
+// them, e.g. "users.id", in which case only that table's column matches.
+func (m *FieldMapper) taggedFieldName(prefix string, tagName string) fieldName {
+	if table, col, ok := strings.Cut(tagName, "."); ok && m.config.Tables != nil {
+		if oid, ok := m.config.Tables[table]; ok {
+			return fieldName{name: prefix + col, exactMatch: true, tableOID: oid}
+		}
+	}
+	return fieldName{name: prefix + tagName, exactMatch: true}
+}
+
+func (m *FieldMapper) makeNamedStructRowField(
+	sf reflect.StructField,
+	tag structTag,
+	prefix string,
+	fieldStack []int,
+) (field namedStructRowField, ok bool) {
 	if sf.PkgPath != "" {
 		return field, false
 	}
-	dbTag, dbTagPresent := sf.Tag.Lookup(structTagKey)
-	var colName string
-	if dbTagPresent {
-		dbTag, _, _ = strings.Cut(dbTag, ",")
-		if dbTag == "-" {
-			// Field is ignored, skip it.
-			return field, false
-		}
-		colName = dbTag
+	var name fieldName
+	if tag.name == "-" {
+		// Field is ignored, skip it.
+		return field, false
+	} else if tag.name != "" {
+		name = m.taggedFieldName(prefix, tag.name)
 	} else {
-		colName = strings.ReplaceAll(sf.Name, "_", "")
+		name = m.fieldName(prefix, sf.Name)
 	}
 	return namedStructRowField{
 		field: structRowField{
 			path: append([]int(nil), fieldStack...),
+			typ:  sf.Type,
 		},
-		fieldName: fieldName {
-			name:       colName,
-			exactMatch: dbTagPresent,
-		},
+		fieldName:   name,
+		requirement: tag.requirement,
+		pos:         tag.pos,
 	}, true
 }
 
-// Map from reflect.Type -> []structRowField
-var structRowFieldsByPosMap sync.Map
+type structRowFieldsByPosEntry struct {
+	info   *structTypeInfo
+	fields StructRowFields
+	err    error
+	// resized maps numbers of columns other than the number of fields to the StructRowFields
+	// mapping a prefix of the fields, or all the fields and discarding the extra columns.
+	resized sync.Map
+}
 
-func GetStructRowFieldsByPos(
+// GetStructRowFieldsByPos returns the fields of typ matching the columns in fldDescs by position.
+// Fields are in declaration order, unless they're tagged with positions.
+// Fields which scan themselves, and the rest field, don't correspond to any column.
+// If lax is true, there may be fewer columns than fields, in which case only a prefix of the
+// fields is mapped to columns.
+func (m *FieldMapper) GetStructRowFieldsByPos(
 	typ reflect.Type,
 	fldDescs []pgconn.FieldDescription,
+	lax bool,
 ) (StructRowFields, error) {
-	fieldsIface, ok := structRowFieldsByPosMap.Load(typ)
+	entryIface, ok := m.structRowFieldsByPosMap.Load(typ)
 	if !ok {
-		namedFields := lookupNamedStructRowFields(typ)
-		fields := make([]structRowField, len(namedFields))
-		for i := range namedFields {
-			fields[i] = namedFields[i].field
+		info := m.lookupStructTypeInfo(typ)
+		fields := make([]structRowField, len(info.fields))
+		for i := range info.fields {
+			if info.byPos != nil {
+				fields[i] = info.fields[info.byPos[i]].field
+			} else {
+				fields[i] = info.fields[i].field
+			}
 		}
-		fieldsIface, _ = structRowFieldsByPosMap.LoadOrStore(typ, fields)
+		err := info.err
+		if err == nil {
+			err = info.posErr
+		}
+		entry := &structRowFieldsByPosEntry{
+			info:   info,
+			fields: newStructRowFields(info, fields, nil, nil),
+			err:    err,
+		}
+		entryIface, _ = m.structRowFieldsByPosMap.LoadOrStore(typ, entry)
+	}
+	entry := entryIface.(*structRowFieldsByPosEntry)
+	if entry.err != nil {
+		return StructRowFields{}, entry.err
 	}
-	fields := fieldsIface.([]structRowField)
-	if len(fields) != len(fldDescs) {
-		return nil, fmt.Errorf(
+	fields := entry.fields
+	if len(fields.fields) == len(fldDescs) {
+		return fields, nil
+	}
+	if (len(fldDescs) < len(fields.fields) && !lax) ||
+		(len(fldDescs) > len(fields.fields) && !m.config.DiscardExtraColumns) {
+		return StructRowFields{}, fmt.Errorf(
 			"got %d values, but dst struct has only %d fields",
 			len(fldDescs),
-			len(fields),
+			len(fields.fields),
 		)
 	}
-	return fields, nil
+	if resized, ok := entry.resized.Load(len(fldDescs)); ok {
+		return resized.(StructRowFields), nil
+	}
+	// Columns without a corresponding field are left unset, so are discarded.
+	resized := make([]structRowField, len(fldDescs))
+	copy(resized, fields.fields)
+	resizedIface, _ := entry.resized.LoadOrStore(
+		len(fldDescs),
+		newStructRowFields(entry.info, resized, nil, nil),
+	)
+	return resizedIface.(StructRowFields), nil
 }
 
-// Map from structRowFieldsByNameKey -> *[]structRowFieldsByNameEntry
+// The structRowFieldsByNameMap cache maps structRowFieldsByNameKey -> *[]structRowFieldsByNameEntry
 // The types and method of managing this cache are funky for the following reasons:
 // Different sets / orders of field keys will produce different []structRowField results,
 // so the column names from the fldDesc list needs to be included as part of the cache key.
@@ -163,7 +601,6 @@ func GetStructRowFieldsByPos(
 // *structRowFieldsByNameEntry, which contains a *structRowFieldsByNameEntry as a field.
 // This would avoid needing to copy the slice on each collision and _might_ have slightly
 // lower overhead otherwise.
-var structRowFieldsByNameMap sync.Map
 
 type structRowFieldsByNameKey struct {
 	typ          reflect.Type
@@ -171,49 +608,94 @@ type structRowFieldsByNameKey struct {
 }
 
 type structRowFieldsByNameEntry struct {
-	cols   []string
-	fields []structRowField
-	// missingField is used to report errors when non-lax mappers don't include every field.
-	// It must be the _first_ field in column order in the type, to be consistent with pgx.
-	missingField string
+	cols []string
+	// tableOIDs contains the table OID of each column, if the mapper has registered tables.
+	tableOIDs []uint32
+	fields    StructRowFields
+	// mismatch is used to report errors when fields or columns don't have a counterpart.
+	mismatch Mismatch
+	// err is the error, if any, which makes the struct type unusable.
+	err error
+}
+
+// Mismatch describes the fields and columns which don't have a counterpart when matching
+// columns to the fields of a struct by name.
+type Mismatch struct {
+	// MissingFields contains the fields without a corresponding column, in field order. This
+	// includes embedded fields shadowed by shallower fields matching the same column.
+	MissingFields []MissingField
+	// UnmatchedColumns contains the columns without a corresponding field, in column order.
+	UnmatchedColumns []string
+}
+
+// MissingField describes a struct field without a corresponding column.
+type MissingField struct {
+	// Field is the name of the field, including the names of the structs containing it,
+	// e.g. "Address.Street".
+	Field string
+	// Column is the name the field matches columns with. Unless the field matches exactly,
+	// this is case-folded with underscores removed, and matches any column whose name is the
+	// same once case-folded with underscores removed.
+	Column string
+
+	requirement requirement
+}
+
+// IsRequired returns true if the field must have a corresponding column, for a lax or strict
+// scanner.
+func (f MissingField) IsRequired(lax bool) bool {
+	switch f.requirement {
+	case requirementOptional:
+		return false
+	case requirementRequired:
+		return true
+	default:
+		return !lax
+	}
+}
+
+// GetStructRowFieldsByName returns the fields of typ matching the columns in fldDescs by name,
+// along with the fields and columns that don't have a counterpart. Columns are left to the rest
+// field, if typ has one, so are never unmatched. Fields which scan themselves don't correspond to
+// any column, but the columns they declare that they read aren't unmatched or left to the rest
+// field.
+// It returns an error if the mapping is ambiguous.
+func (m *FieldMapper) GetStructRowFieldsByName(
+	typ reflect.Type,
+	fldDescs []pgconn.FieldDescription,
+) (StructRowFields, Mismatch, error) {
+	entry := m.lookupStructRowFieldsByNameEntry(typ, fldDescs)
+	if entry.err != nil {
+		return StructRowFields{}, Mismatch{}, entry.err
+	}
+	return entry.fields, entry.mismatch, nil
 }
 
-func GetStructRowFieldsByName(
+func (m *FieldMapper) lookupStructRowFieldsByNameEntry(
 	typ reflect.Type,
 	fldDescs []pgconn.FieldDescription,
-) (StructRowFields, string, error) {
+) *structRowFieldsByNameEntry {
 	key := structRowFieldsByNameKey{
 		typ:          typ,
-		hashColNames: hashColNames(fldDescs),
+		hashColNames: hashColNames(fldDescs, m.config.Tables != nil),
 	}
 	var entries []structRowFieldsByNameEntry
-	entriesIface, ok := structRowFieldsByNameMap.Load(key)
+	entriesIface, ok := m.structRowFieldsByNameMap.Load(key)
 	if !ok {
 		// Ensure the map contains an entry for the key, so we can compare-and-swap later.
 		var entriesBox []structRowFieldsByNameEntry
-		entriesIface, ok = structRowFieldsByNameMap.LoadOrStore(key, &entriesBox)
+		entriesIface, ok = m.structRowFieldsByNameMap.LoadOrStore(key, &entriesBox)
 	}
 	if ok {
 		// Make sure one of the entries actually matches this field-set.
 		entries = *(entriesIface.(*[]structRowFieldsByNameEntry))
-		for _, e := range entries {
-			if colsMatch(fldDescs, e.cols) {
-				return e.fields, e.missingField, nil
+		for i := range entries {
+			if colsMatch(fldDescs, entries[i].cols, entries[i].tableOIDs) {
+				return &entries[i]
 			}
 		}
 	}
-	newEntry := buildNamedStructRowFieldsEntry(typ, fldDescs)
-	for i, f := range newEntry.fields {
-		if !f.isSet() {
-			// In this case, this mapping is never valid. We could cache the failure, but
-			// for now it doesn't seem worthwhile to optimize error paths, so we just error out.
-			// This could be expensive if a broken query is issued repeatedly.
-			return nil, newEntry.missingField, fmt.Errorf(
-				"struct doesn't have corresponding row field %s",
-				fldDescs[i].Name,
-			)
-		}
-	}
+	newEntry := m.buildNamedStructRowFieldsEntry(typ, fldDescs)
 
 	// Copy existing entries to a new slice, adding the newEntry. Loop to compare-and-swap in
 	// the slice, to make sure we actually cache our result but don't clobber anyone else's
@@ -226,10 +708,10 @@ func GetStructRowFieldsByName(
 		// TODO: CompareAndSwap doesn't exist in 1.19, whih is the main reason support is dropped.
 		// We could probably work around this by storing an atomic.Pointer in the map and doing
 		// the CAS on the pointer rather than the map key.
-		if structRowFieldsByNameMap.CompareAndSwap(key, entriesIface, &newEntries) {
-			return newEntry.fields, newEntry.missingField, nil
+		if m.structRowFieldsByNameMap.CompareAndSwap(key, entriesIface, &newEntries) {
+			return &newEntries[0]
 		}
-		entriesIface, _ = structRowFieldsByNameMap.Load(key)
+		entriesIface, _ = m.structRowFieldsByNameMap.Load(key)
 		entries = *(entriesIface.(*[]structRowFieldsByNameEntry))
 
 		// It's possible (likely?) that if the CAS failed, we conflicted with another operation
@@ -238,45 +720,181 @@ func GetStructRowFieldsByName(
 		// We could probably optimize this loop to only look at a subset of the entries,
 		// since some will be repeated from earlier loops. However, it's very unlikely
 		// we get here anyway.
-		for _, e := range entries {
-			if colsMatch(fldDescs, e.cols) {
-				return e.fields, e.missingField, nil
+		for i := range entries {
+			if colsMatch(fldDescs, entries[i].cols, entries[i].tableOIDs) {
+				return &entries[i]
 			}
 		}
 	}
 }
 
-func buildNamedStructRowFieldsEntry(
+func (m *FieldMapper) buildNamedStructRowFieldsEntry(
 	typ reflect.Type,
 	fldDescs []pgconn.FieldDescription,
 ) structRowFieldsByNameEntry {
-	namedFields := lookupNamedStructRowFields(typ)
+	info := m.lookupStructTypeInfo(typ)
 	fields := make([]structRowField, len(fldDescs))
-	var missingField string
-	for i := range namedFields {
-		f := &namedFields[i]
-		fpos := fieldPosByName(fldDescs, f.fieldName)
+	idx := newColumnIndex(fldDescs)
+	// owners contains the name of the field or setter mapped to each column, if any.
+	owners := make([]string, len(fldDescs))
+	var mismatch Mismatch
+	// err reports the first ambiguous mapping, if any. Otherwise, one of the fields or columns
+	// involved would be silently ignored.
+	err := info.err
+	fieldMatches := make([]colMatches, len(info.fields))
+	// depths contains the embedding depth of the shallowest promoted field matching each column.
+	// Like Go's promoted fields, a promoted field shadows the deeper promoted fields matching the
+	// same column. Other fields, e.g. the fields of prefixed structs, never shadow each other.
+	depths := make([]int, len(fldDescs))
+	for i := range info.fields {
+		f := &info.fields[i]
+		fieldMatches[i] = idx.lookup(f.fieldName)
+		fpos := fieldMatches[i].first
+		if fpos != -1 && f.promoted && (depths[fpos] == 0 || len(f.field.path) < depths[fpos]) {
+			depths[fpos] = len(f.field.path)
+		}
+	}
+	for i := range info.fields {
+		f := &info.fields[i]
+		matches := fieldMatches[i]
+		fpos := matches.first
 		if fpos == -1 {
-			if missingField == "" {
-				missingField = f.name
+			mismatch.MissingFields = append(mismatch.MissingFields, MissingField{
+				Field:       structFieldPathName(typ, f.field.path),
+				Column:      f.name,
+				requirement: f.requirement,
+			})
+			continue
+		}
+		if f.promoted && len(f.field.path) > depths[fpos] {
+			// The shadowed field doesn't have a column of its own, so is reported as missing.
+			// It's only required if it's tagged as such, since shadowing an embedded field is
+			// deliberate.
+			requirement := requirementOptional
+			if f.requirement == requirementRequired {
+				requirement = requirementRequired
 			}
+			mismatch.MissingFields = append(mismatch.MissingFields, MissingField{
+				Field:       structFieldPathName(typ, f.field.path),
+				Column:      f.name,
+				requirement: requirement,
+			})
 			continue
 		}
+		name := structFieldPathName(typ, f.field.path)
+		if err == nil {
+			err = ambiguousFieldErr(fldDescs, owners, name, matches)
+		}
 		fields[fpos] = f.field
+		owners[fpos] = name
 	}
+	var setters []setterCol
+	for i := range info.setters {
+		s := &info.setters[i]
+		matches := idx.lookup(s.fieldName)
+		fpos := matches.first
+		if fpos == -1 {
+			// Setters are optional, since types may have setters unrelated to any column.
+			mismatch.MissingFields = append(mismatch.MissingFields, MissingField{
+				Field:       s.methodName,
+				Column:      s.name,
+				requirement: requirementOptional,
+			})
+			continue
+		}
+		if err == nil {
+			err = ambiguousFieldErr(fldDescs, owners, s.methodName, matches)
+		}
+		setters = append(setters, setterCol{col: fpos, setter: s})
+		owners[fpos] = s.methodName
+	}
+	var restCols []int
 	cols := make([]string, len(fldDescs))
+	var tableOIDs []uint32
+	if m.config.Tables != nil {
+		tableOIDs = make([]uint32, len(fldDescs))
+	}
 	for i := range fldDescs {
 		cols[i] = fldDescs[i].Name
+		if tableOIDs != nil {
+			tableOIDs[i] = fldDescs[i].TableOID
+		}
+		// Columns which fields that scan themselves declare that they read are claimed by them.
+		if owners[i] != "" || info.selfScannedCols[fldDescs[i].Name] {
+			continue
+		}
+		if info.rest.isSet() {
+			if err == nil {
+				err = duplicateRestColErr(typ, info, fldDescs, restCols, i)
+			}
+			restCols = append(restCols, i)
+		} else {
+			mismatch.UnmatchedColumns = append(mismatch.UnmatchedColumns, fldDescs[i].Name)
+		}
 	}
 	entry := structRowFieldsByNameEntry{
-		cols:         cols,
-		fields:       fields,
-		missingField: missingField,
+		cols:      cols,
+		tableOIDs: tableOIDs,
+		fields:    newStructRowFields(info, fields, restCols, setters),
+		mismatch:  mismatch,
+		err:       err,
 	}
 	return entry
 }
 
-func colsMatch(fldDescs []pgconn.FieldDescription, colNames []string) bool {
+// ambiguousFieldErr returns an error if the field with the given name matches multiple columns,
+// or if the first column it matches already has an owner.
+func ambiguousFieldErr(
+	fldDescs []pgconn.FieldDescription,
+	owners []string,
+	name string,
+	matches colMatches,
+) error {
+	fpos := matches.first
+	if dup := matches.second; dup != -1 {
+		return fmt.Errorf(
+			"row fields %s and %s both match struct field %s",
+			fldDescs[fpos].Name,
+			fldDescs[dup].Name,
+			name,
+		)
+	}
+	if owners[fpos] != "" {
+		return fmt.Errorf(
+			"struct fields %s and %s both match row field %s",
+			owners[fpos],
+			name,
+			fldDescs[fpos].Name,
+		)
+	}
+	return nil
+}
+
+// duplicateRestColErr returns an error if the column at pos has the same name as one of the
+// columns in restCols. Otherwise, only one of their values would be kept in the rest field.
+func duplicateRestColErr(
+	typ reflect.Type,
+	info *structTypeInfo,
+	fldDescs []pgconn.FieldDescription,
+	restCols []int,
+	pos int,
+) error {
+	for _, col := range restCols {
+		if fldDescs[col].Name == fldDescs[pos].Name {
+			return fmt.Errorf(
+				"row fields %s and %s both match rest field %s",
+				fldDescs[col].Name,
+				fldDescs[pos].Name,
+				structFieldPathName(typ, info.rest.path),
+			)
+		}
+	}
+	return nil
+}
+
+// colsMatch returns true if fldDescs has the given column names and, if tableOIDs is non-nil,
+// table OIDs.
+func colsMatch(fldDescs []pgconn.FieldDescription, colNames []string, tableOIDs []uint32) bool {
 	if len(fldDescs) != len(colNames) {
 		return false
 	}
@@ -285,61 +903,87 @@ func colsMatch(fldDescs []pgconn.FieldDescription, colNames []string) bool {
 			return false
 		}
 	}
+	for i, oid := range tableOIDs {
+		if fldDescs[i].TableOID != oid {
+			return false
+		}
+	}
 	return true
 }
 
-func hashColNames(fldDescs []pgconn.FieldDescription) uint64 {
-	hasher := fnv.New64a()
-	var zeroByte [1]byte
-	_ = zeroByte
+func hashColNames(fldDescs []pgconn.FieldDescription, withTableOIDs bool) uint64 {
+	hasher := newFNV64a()
 	for _, f := range fldDescs {
-		// bs := unsafe.Slice(unsafe.StringData(f.Name), len(f.Name))
-		bs := unsafeStringToBytes(f.Name)
-		hasher.Write(bs)
+		hasher.writeString(f.Name)
 		// Writing zero bytes between field names reduces the likelihood of collisions.
 		// E.g. "aa","a" hash the same as "a","aa" without zeroes, but different with them.
-		hasher.Write(zeroByte[:])
+		hasher.writeByte(0)
+		if withTableOIDs {
+			hasher.writeUint32(f.TableOID)
+		}
 	}
-	return hasher.Sum64()
+	return uint64(hasher)
 }
 
-// TODO: If we reduce the version requirement back down to 1.19, we need to conditionally
-// compile a version of this that works.
-func unsafeStringToBytes(s string) []byte {
-	return unsafe.Slice(unsafe.StringData(s), len(s))
+// fnv64a is the 64-bit FNV-1a hash, equivalent to hash/fnv's New64a. Unlike hash/fnv, its
+// methods aren't called through the hash.Hash interface, so the data written to it never escapes
+// to the heap, regardless of what the compiler is able to devirtualize.
+type fnv64a uint64
+
+const (
+	fnv64aOffset = 14695981039346656037
+	fnv64aPrime  = 1099511628211
+)
+
+func newFNV64a() fnv64a {
+	return fnv64aOffset
 }
 
-func fieldPosByName(fldDescs []pgconn.FieldDescription, field fieldName) (i int) {
-	i = -1
-	if field.exactMatch {
-		for i, desc := range fldDescs {
-			if desc.Name == field.name {
-				return i
-			}
+func (h *fnv64a) writeByte(b byte) {
+	*h ^= fnv64a(b)
+	*h *= fnv64aPrime
+}
+
+func (h *fnv64a) writeString(s string) {
+	for i := 0; i < len(s); i++ {
+		h.writeByte(s[i])
+	}
+}
+
+// writeUint32 writes v in little-endian byte order.
+func (h *fnv64a) writeUint32(v uint32) {
+	for i := 0; i < 4; i++ {
+		h.writeByte(byte(v >> (8 * i)))
+	}
+}
+
+// structFieldPathName returns the name of the field of typ at path, including the names of the
+// structs containing it, e.g. "Address.Street".
+func structFieldPathName(typ reflect.Type, path []int) string {
+	var b strings.Builder
+	for _, i := range path {
+		if typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
 		}
-	} else {
-		for i, desc := range fldDescs {
-			// Snake case support.
-			descName := strings.ReplaceAll(desc.Name, "_", "")
-			if strings.EqualFold(descName, field.name) {
-				return i
-			}
+		sf := typ.Field(i)
+		if b.Len() > 0 {
+			b.WriteByte('.')
 		}
+		b.WriteString(sf.Name)
+		typ = sf.Type
 	}
-	return
+	return b.String()
 }
 
-// ClearCaches clears all caches of struct field information. This is intended only for testing.
-// This is not synchronized with access of the caches, and so must not be called
-// simultaneously with any active use of pgx-collect.
+// ClearStructFieldCaches clears all caches of struct field information of the DefaultFieldMapper.
+// This is intended only for testing. This is not synchronized with access of the caches,
+// and so must not be called simultaneously with any active use of pgx-collect.
 func ClearStructFieldCaches() {
-	namedStructRowFieldMap = sync.Map{}
-	structRowFieldsByPosMap = sync.Map{}
-	structRowFieldsByNameMap = sync.Map{}
+	DefaultFieldMapper.clearCaches()
 }
 
-// CollidingFieldSets returns all field-sets for a type that collided in the hash-table with
-// the given keys. (Including the provided one, assuming it's in the table.)
+// CollidingFieldSets returns all field-sets for a type that collided in the DefaultFieldMapper's
+// hash-table with the given keys. (Including the provided one, assuming it's in the table.)
 func CollidingFieldSets(typ reflect.Type, fields []string) [][]string {
 	fldDescs := make([]pgconn.FieldDescription, len(fields))
 	for i, f := range fields {
@@ -347,9 +991,9 @@ func CollidingFieldSets(typ reflect.Type, fields []string) [][]string {
 	}
 	key := structRowFieldsByNameKey{
 		typ:          typ,
-		hashColNames: hashColNames(fldDescs),
+		hashColNames: hashColNames(fldDescs, false),
 	}
-	entriesIface, ok := structRowFieldsByNameMap.Load(key)
+	entriesIface, ok := DefaultFieldMapper.structRowFieldsByNameMap.Load(key)
 	if !ok {
 		return nil
 	}
@@ -85,3 +85,25 @@ func checkScanOne[T any](
 	require.NoError(t, err)
 	require.Equal(t, expected, val)
 }
+
+func TestNamedStructCacheLookupAllocs(t *testing.T) {
+	type user struct {
+		ID   int    `db:"users.id"`
+		Name string `db:"users.name"`
+	}
+	mapper := pgxc_internal.NewFieldMapper(pgxc_internal.MapperConfig{
+		Tables: map[string]uint32{"users": 1},
+	})
+	typ := reflect.TypeOf(user{})
+	fldDescs := MakeMockRows("id,name", nil).FieldDescriptions()
+	for i := range fldDescs {
+		fldDescs[i].TableOID = 1
+	}
+
+	_, _, err := mapper.GetStructRowFieldsByName(typ, fldDescs)
+	require.NoError(t, err)
+	allocs := testing.AllocsPerRun(100, func() {
+		mapper.GetStructRowFieldsByName(typ, fldDescs)
+	})
+	require.Zero(t, allocs)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type rows [][]any
@@ -106,8 +107,58 @@ func (m *MockRows) Scan(dest ...any) error {
 		return fmt.Errorf("len(data) (%v) != len(dest) (%v)", data, dest)
 	}
 	for i, d := range dest {
+		if d == nil {
+			// Like pgx, a nil destination skips the column.
+			continue
+		}
 		elem := data[i]
-		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(elem))
+		if bs, ok := d.(pgtype.BytesScanner); ok {
+			var src []byte
+			switch elem := elem.(type) {
+			case nil:
+			case []byte:
+				src = elem
+			default:
+				src = []byte(fmt.Sprint(elem))
+			}
+			if err := bs.ScanBytes(src); err != nil {
+				return err
+			}
+			continue
+		}
+		if ts, ok := d.(pgtype.TextScanner); ok {
+			// Like pgx, any value can be scanned as text.
+			var text pgtype.Text
+			if elem != nil {
+				text = pgtype.Text{String: fmt.Sprint(elem), Valid: true}
+			}
+			if err := ts.ScanText(text); err != nil {
+				return err
+			}
+			continue
+		}
+		dst := reflect.ValueOf(d).Elem()
+		if elem == nil {
+			switch dst.Kind() {
+			case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+				dst.Set(reflect.Zero(dst.Type()))
+			default:
+				return fmt.Errorf("cannot scan NULL into %T", d)
+			}
+			continue
+		}
+		src := reflect.ValueOf(elem)
+		if src.Kind() == dst.Kind() && src.Type().ConvertibleTo(dst.Type()) {
+			// Like pgx, values are scanned into types by their underlying kind.
+			src = src.Convert(dst.Type())
+		}
+		if dst.Kind() == reflect.Pointer && src.Type().AssignableTo(dst.Type().Elem()) {
+			// Like pgx, a pointer destination is allocated for non-NULL values.
+			ptr := reflect.New(dst.Type().Elem())
+			ptr.Elem().Set(src)
+			src = ptr
+		}
+		dst.Set(src)
 	}
 	return nil
 }
@@ -128,8 +179,24 @@ func (m *MockRows) Close() {
 }
 
 func (m *MockRows) RawValues() [][]byte {
-	// We need to implement this much in order to work with pgx.RowToStructByPos
-	return make([][]byte, len(m.descs))
+	raw := make([][]byte, len(m.descs))
+	if m.done || m.rowIdx < 0 || m.rowIdx >= len(m.data) {
+		// We need to implement this much in order to work with pgx.RowToStructByPos
+		return raw
+	}
+	// The raw value of a column is its text representation, or nil if it is NULL.
+	for i, v := range m.data[m.rowIdx] {
+		switch v := v.(type) {
+		case nil:
+		case []byte:
+			raw[i] = v
+		case string:
+			raw[i] = []byte(v)
+		default:
+			raw[i] = fmt.Append(nil, v)
+		}
+	}
+	return raw
 }
 
 func (m *MockRows) Conn() *pgx.Conn {
@@ -0,0 +1,259 @@
+package pgx_collect
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	. "github.com/zolstein/pgx-collect/internal"
+)
+
+// Mapper configures how the struct scanners map columns to struct fields.
+//
+// A Mapper caches information about the types it maps, and caches are not shared between
+// Mappers. Therefore, a Mapper should be created once and reused, e.g. as a package-level
+// variable, rather than created for each query.
+type Mapper struct {
+	mapper *FieldMapper
+}
+
+// MapperOption configures a Mapper.
+type MapperOption func(*MapperConfig)
+
+// NewMapper returns a Mapper configured with the given options. Without any options, the
+// Mapper behaves the same as the one used by RowToStructByName, etc.
+func NewMapper(opts ...MapperOption) *Mapper {
+	var config MapperConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return &Mapper{mapper: NewFieldMapper(config)}
+}
+
+// NameMatching is a strategy for matching struct fields without a "db" tag to columns.
+type NameMatching struct {
+	columnName func(fieldName string) string
+}
+
+var (
+	// MatchFolded matches columns case-insensitively, ignoring underscores.
+	// E.g. the field UserID matches the columns user_id, userid and USER_ID.
+	// This is the default strategy, and is consistent with pgx.
+	MatchFolded = NameMatching{}
+	// MatchExact matches columns with exactly the field name.
+	// E.g. the field UserID matches the column UserID.
+	MatchExact = NameMatching{columnName: func(fieldName string) string { return fieldName }}
+	// MatchSnakeCase matches columns with exactly the snake_case form of the field name.
+	// E.g. the field UserID matches the column user_id.
+	MatchSnakeCase = NameMatching{columnName: snakeCase}
+	// MatchCamelCase matches columns with exactly the camelCase form of the field name.
+	// E.g. the field UserID matches the column userID.
+	MatchCamelCase = NameMatching{columnName: camelCase}
+)
+
+// MatchFunc matches columns with exactly the name returned by columnName for the field name.
+func MatchFunc(columnName func(fieldName string) string) NameMatching {
+	return NameMatching{columnName: columnName}
+}
+
+// WithNameMatching sets the strategy for matching struct fields without a "db" tag to columns.
+// Prefixes from the "prefix" tag option are prepended to the converted field names.
+func WithNameMatching(matching NameMatching) MapperOption {
+	return func(config *MapperConfig) {
+		config.ColumnName = matching.columnName
+	}
+}
+
+// WithTagKeys sets the keys of the struct tags used to configure how fields are mapped to
+// columns, in place of "db". If a field has more than one of the tags, the first key takes
+// precedence. E.g. with WithTagKeys("sql", "json"), a field with both `sql:"id"` and
+// `json:"userId"` tags is mapped to the column id.
+func WithTagKeys(keys ...string) MapperOption {
+	return func(config *MapperConfig) {
+		config.TagKeys = append([]string(nil), keys...)
+	}
+}
+
+// WithTables registers tables by name, along with their OIDs. A field's tag may then qualify
+// the column name with the name of one of the tables, e.g. `db:"users.id"`, in which case the
+// field only matches the column of that name belonging to the table. This allows scanning the
+// results of a JOIN with duplicate column names, e.g. "SELECT u.*, o.* FROM users u JOIN orders o
+// ...", without aliasing the columns. The OIDs can be queried with "SELECT 'users'::regclass::oid".
+//
+// The columns of a query only have a table OID if they come directly from a table, rather than
+// from an expression.
+func WithTables(tables map[string]uint32) MapperOption {
+	return func(config *MapperConfig) {
+		config.Tables = make(map[string]uint32, len(tables))
+		for name, oid := range tables {
+			config.Tables[name] = oid
+		}
+	}
+}
+
+// WithUnsafeFieldAccess makes the struct scanners construct pointers to fields from their
+// offsets using package unsafe, rather than by traversing the struct with reflection, when
+// scanning each row. This reduces the overhead of scanning wide structs, particularly those with
+// embedded structs.
+func WithUnsafeFieldAccess() MapperOption {
+	return func(config *MapperConfig) {
+		config.UnsafeFieldAccess = true
+	}
+}
+
+// WithNullZero makes the struct scanners scan NULL into all fields as their zero value, as if
+// they were tagged "nullzero", rather than failing for fields that can't represent NULL.
+func WithNullZero() MapperOption {
+	return func(config *MapperConfig) {
+		config.NullZero = true
+	}
+}
+
+// WithJSONDecoder sets the function used to decode columns into fields tagged "json", in place of
+// json.Unmarshal. The buffer passed as data is reused between rows, so must be copied if it is
+// retained.
+func WithJSONDecoder(unmarshal func(data []byte, v any) error) MapperOption {
+	return func(config *MapperConfig) {
+		config.JSONUnmarshal = unmarshal
+	}
+}
+
+// WithSetters makes the struct scanners which map columns by name also map columns to setter
+// methods, for types which keep their fields unexported. A method of *T named Set<Name>, which
+// takes a single argument and returns either nothing or an error, is mapped to columns as if it
+// were a field named <Name>, unless T has such an exported field. The column is scanned into a
+// temporary value of the argument's type, and the method is called with it after scanning.
+// Setters are optional, like fields tagged "optional", so setters without a corresponding column
+// aren't an error, even for strict scanners.
+func WithSetters() MapperOption {
+	return func(config *MapperConfig) {
+		config.Setters = true
+	}
+}
+
+// WithDiscardExtraColumns makes the struct scanners which map columns by position discard the
+// columns after those mapped to the struct's fields, rather than failing. E.g. a struct with two
+// fields can scan the first two columns of "SELECT id, name, created_at ...".
+func WithDiscardExtraColumns() MapperOption {
+	return func(config *MapperConfig) {
+		config.DiscardExtraColumns = true
+	}
+}
+
+// RowToStructByPosWith is like RowToStructByPos, but maps columns to fields using mapper.
+func RowToStructByPosWith[T any](mapper *Mapper) RowSpec[T] {
+	return func() rowSpecRes[T] {
+		return rowSpecRes[T]{fn: func() Scanner[T] {
+			return newPositionalStructScannerWith[T](mapper.mapper)
+		}}
+	}
+}
+
+// RowToAddrOfStructByPosWith is like RowToAddrOfStructByPos, but maps columns to fields using
+// mapper.
+func RowToAddrOfStructByPosWith[T any](mapper *Mapper) RowSpec[*T] {
+	return func() rowSpecRes[*T] {
+		return rowSpecRes[*T]{fn: func() Scanner[*T] {
+			return newAddrScanner[T](newPositionalStructScannerWith[T](mapper.mapper))
+		}}
+	}
+}
+
+// RowToStructByPosLaxWith is like RowToStructByPosLax, but maps columns to fields using mapper.
+func RowToStructByPosLaxWith[T any](mapper *Mapper) RowSpec[T] {
+	return func() rowSpecRes[T] {
+		return rowSpecRes[T]{fn: func() Scanner[T] {
+			return newLaxPositionalStructScannerWith[T](mapper.mapper)
+		}}
+	}
+}
+
+// RowToAddrOfStructByPosLaxWith is like RowToAddrOfStructByPosLax, but maps columns to fields
+// using mapper.
+func RowToAddrOfStructByPosLaxWith[T any](mapper *Mapper) RowSpec[*T] {
+	return func() rowSpecRes[*T] {
+		return rowSpecRes[*T]{fn: func() Scanner[*T] {
+			return newAddrScanner[T](newLaxPositionalStructScannerWith[T](mapper.mapper))
+		}}
+	}
+}
+
+// RowToStructByNameWith is like RowToStructByName, but maps columns to fields using mapper.
+func RowToStructByNameWith[T any](mapper *Mapper) RowSpec[T] {
+	return func() rowSpecRes[T] {
+		return rowSpecRes[T]{fn: func() Scanner[T] {
+			return newNamedStructScannerWith[T](mapper.mapper)
+		}}
+	}
+}
+
+// RowToAddrOfStructByNameWith is like RowToAddrOfStructByName, but maps columns to fields
+// using mapper.
+func RowToAddrOfStructByNameWith[T any](mapper *Mapper) RowSpec[*T] {
+	return func() rowSpecRes[*T] {
+		return rowSpecRes[*T]{fn: func() Scanner[*T] {
+			return newAddrScanner[T](newNamedStructScannerWith[T](mapper.mapper))
+		}}
+	}
+}
+
+// RowToStructByNameLaxWith is like RowToStructByNameLax, but maps columns to fields using mapper.
+func RowToStructByNameLaxWith[T any](mapper *Mapper) RowSpec[T] {
+	return func() rowSpecRes[T] {
+		return rowSpecRes[T]{fn: func() Scanner[T] {
+			return newLaxNamedStructScannerWith[T](mapper.mapper)
+		}}
+	}
+}
+
+// RowToAddrOfStructByNameLaxWith is like RowToAddrOfStructByNameLax, but maps columns to fields
+// using mapper.
+func RowToAddrOfStructByNameLaxWith[T any](mapper *Mapper) RowSpec[*T] {
+	return func() rowSpecRes[*T] {
+		return rowSpecRes[*T]{fn: func() Scanner[*T] {
+			return newAddrScanner[T](newLaxNamedStructScannerWith[T](mapper.mapper))
+		}}
+	}
+}
+
+// snakeCase converts a Go identifier into snake_case. Runs of capitals are treated as a single
+// word, e.g. "UserID" becomes "user_id" and "HTTPServer" becomes "http_server".
+func snakeCase(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 4)
+	var prev rune
+	for i, r := range s {
+		if unicode.IsUpper(r) && i > 0 && prev != '_' {
+			next, _ := utf8.DecodeRuneInString(s[i+utf8.RuneLen(r):])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && unicode.IsLower(next)) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+		prev = r
+	}
+	return b.String()
+}
+
+// camelCase converts a Go identifier into camelCase by lower-casing its first word.
+// Runs of capitals are treated as a single word, e.g. "UserID" becomes "userID" and
+// "HTTPServer" becomes "httpServer".
+func camelCase(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i, r := range s {
+		if !unicode.IsUpper(r) {
+			b.WriteString(s[i:])
+			break
+		}
+		next, _ := utf8.DecodeRuneInString(s[i+utf8.RuneLen(r):])
+		if i > 0 && unicode.IsLower(next) {
+			// r starts the next word.
+			b.WriteString(s[i:])
+			break
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
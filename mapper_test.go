@@ -0,0 +1,322 @@
+package pgx_collect_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pgxc "github.com/zolstein/pgx-collect"
+	. "github.com/zolstein/pgx-collect/internal/testutils"
+)
+
+func TestMapperNameMatching(t *testing.T) {
+	type Inner struct {
+		ZipCode string
+	}
+	type account struct {
+		UserID     int
+		HTTPServer string
+		Name       string
+		Field1     int
+		Tagged     string `db:"TAG"`
+		Inner      Inner  `db:"inner,prefix"`
+	}
+	expected := account{1, "srv", "Alice", 2, "tag", Inner{"12345"}}
+	values := OneRow(1, "srv", "Alice", 2, "tag", "12345")
+
+	tests := []struct {
+		name    string
+		mapper  *pgxc.Mapper
+		valid   string
+		invalid []string
+	}{
+		{
+			name:   "folded",
+			mapper: pgxc.NewMapper(pgxc.WithNameMatching(pgxc.MatchFolded)),
+			valid:  "user_id,HTTP_server,name,field_1,TAG,inner_zip_code",
+			invalid: []string{
+				"user_id,http_server,name,field1,tag,innerzipcode",
+			},
+		},
+		{
+			name:   "exact",
+			mapper: pgxc.NewMapper(pgxc.WithNameMatching(pgxc.MatchExact)),
+			valid:  "UserID,HTTPServer,Name,Field1,TAG,inner_ZipCode",
+			invalid: []string{
+				"userid,HTTPServer,Name,Field1,TAG,inner_ZipCode",
+				"UserID,HTTPServer,Name,Field1,TAG,innerZipCode",
+			},
+		},
+		{
+			name:   "snake-case",
+			mapper: pgxc.NewMapper(pgxc.WithNameMatching(pgxc.MatchSnakeCase)),
+			valid:  "user_id,http_server,name,field1,TAG,inner_zip_code",
+			invalid: []string{
+				"userid,http_server,name,field1,TAG,inner_zip_code",
+				"user_id,httpserver,name,field1,TAG,inner_zip_code",
+				"USER_ID,http_server,name,field1,TAG,inner_zip_code",
+			},
+		},
+		{
+			name:   "camel-case",
+			mapper: pgxc.NewMapper(pgxc.WithNameMatching(pgxc.MatchCamelCase)),
+			valid:  "userID,httpServer,name,field1,TAG,inner_zipCode",
+			invalid: []string{
+				"userId,httpServer,name,field1,TAG,inner_zipCode",
+				"user_id,httpServer,name,field1,TAG,inner_zipCode",
+			},
+		},
+		{
+			name:   "func",
+			mapper: pgxc.NewMapper(pgxc.WithNameMatching(pgxc.MatchFunc(strings.ToUpper))),
+			valid:  "USERID,HTTPSERVER,NAME,FIELD1,TAG,inner_ZIPCODE",
+			invalid: []string{
+				"userid,HTTPSERVER,NAME,FIELD1,TAG,inner_ZIPCODE",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := MakeMockRows(tt.valid, values)
+			checkScanOne(t, rows, pgxc.RowToStructByNameWith[account](tt.mapper), nil, expected)
+			rows = MakeMockRows(tt.valid, values)
+			checkScanOne(t, rows, pgxc.RowToStructByNameLaxWith[account](tt.mapper), nil, expected)
+			rows = MakeMockRows(tt.valid, values)
+			checkScanOne(t, rows, pgxc.RowToAddrOfStructByNameWith[account](tt.mapper), nil, &expected)
+			rows = MakeMockRows(tt.valid, values)
+			checkScanOne(t, rows, pgxc.RowToAddrOfStructByNameLaxWith[account](tt.mapper), nil, &expected)
+			for _, cols := range tt.invalid {
+				rows := MakeMockRows(cols, values)
+				checkInitFails(t, rows, pgxc.RowToStructByNameWith[account](tt.mapper), nil)
+				rows = MakeMockRows(cols, values)
+				checkInitFails(t, rows, pgxc.RowToStructByNameLaxWith[account](tt.mapper), nil)
+			}
+		})
+	}
+}
+
+func TestMapperTagKeys(t *testing.T) {
+	type Inner struct {
+		Zip string `sql:"zip_code"`
+	}
+	type account struct {
+		ID      int    `sql:"id" json:"userId" db:"user_id"`
+		Name    string `json:"userName"`
+		Ignored string `sql:"-" json:"ignored"`
+		Inner   Inner  `sql:",prefix=inner_"`
+	}
+	expected := account{ID: 1, Name: "Alice", Inner: Inner{"12345"}}
+
+	mapper := pgxc.NewMapper(pgxc.WithTagKeys("sql", "json"))
+	{
+		rows := MakeMockRows("id,userName,inner_zip_code", OneRow(1, "Alice", "12345"))
+		checkScanOne(t, rows, pgxc.RowToStructByNameWith[account](mapper), nil, expected)
+	}
+	{
+		rows := MakeMockRows("id,userName,inner_zip_code", OneRow(1, "Alice", "12345"))
+		checkScanOne(t, rows, pgxc.RowToAddrOfStructByPosWith[account](mapper), nil, &expected)
+	}
+	{
+		rows := MakeMockRows("user_id,userName,inner_zip_code", OneRow(1, "Alice", "12345"))
+		checkInitFails(t, rows, pgxc.RowToStructByNameWith[account](mapper), nil)
+	}
+	{
+		rows := MakeMockRows("id,userName,inner_zip_code,ignored", OneRow(1, "Alice", "12345", ""))
+		checkInitFails(t, rows, pgxc.RowToStructByPosWith[account](mapper), nil)
+	}
+
+	// The same type is cached separately for the default mapper.
+	{
+		type account struct {
+			ID   int    `sql:"id" db:"user_id"`
+			Name string `sql:"name" db:"user_name"`
+		}
+		rows := MakeMockRows("id,name", OneRow(1, "Alice"))
+		checkScanOne(
+			t,
+			rows,
+			pgxc.RowToStructByNameWith[account](pgxc.NewMapper(pgxc.WithTagKeys("sql"))),
+			nil,
+			account{1, "Alice"},
+		)
+		rows = MakeMockRows("user_id,user_name", OneRow(1, "Alice"))
+		checkStructByNameSuccess(t, rows, account{1, "Alice"})
+	}
+}
+
+func TestMapperTables(t *testing.T) {
+	const usersOID, ordersOID = 1001, 1002
+	type userOrder struct {
+		UserID  int `db:"users.id"`
+		Name    string
+		OrderID int `db:"orders.id"`
+		Total   int
+	}
+	mapper := pgxc.NewMapper(pgxc.WithTables(map[string]uint32{
+		"users":  usersOID,
+		"orders": ordersOID,
+	}))
+	makeRows := func(oids ...uint32) *MockRows {
+		rows := MakeMockRows("id,name,id,total", OneRow(1, "Alice", 2, 100))
+		for i, oid := range oids {
+			rows.FieldDescriptions()[i].TableOID = oid
+		}
+		return rows
+	}
+
+	rows := makeRows(usersOID, usersOID, ordersOID, ordersOID)
+	checkScanOne(t, rows, pgxc.RowToStructByNameWith[userOrder](mapper), nil, userOrder{1, "Alice", 2, 100})
+
+	// The same column names from other tables are cached separately.
+	rows = makeRows(ordersOID, ordersOID, usersOID, usersOID)
+	checkScanOne(t, rows, pgxc.RowToStructByNameWith[userOrder](mapper), nil, userOrder{2, "Alice", 1, 100})
+
+	rows = makeRows(usersOID, usersOID, usersOID, ordersOID)
+	checkInitFails(t, rows, pgxc.RowToStructByNameWith[userOrder](mapper), nil)
+
+	// Without registered tables, the qualified names are matched literally.
+	rows = makeRows(usersOID, usersOID, ordersOID, ordersOID)
+	checkInitFails(t, rows, pgxc.RowToStructByNameWith[userOrder](pgxc.NewMapper()), nil)
+}
+
+func TestMapperUnsafeFieldAccess(t *testing.T) {
+	type Country struct {
+		Code string
+	}
+	type Address struct {
+		Street  string
+		City    *string
+		Country *Country `db:"country,prefix"`
+	}
+	type Audit struct {
+		CreatedBy string
+	}
+	type person struct {
+		ID int64
+		Audit
+		Name    string
+		Address *Address `db:"addr,prefix"`
+	}
+	mapper := pgxc.NewMapper(pgxc.WithUnsafeFieldAccess())
+
+	rows := MakeMockRows("id,created_by,name,addr_street,addr_city,addr_country_code", [][]any{
+		{int64(1), "admin", "Alice", "Main St", Ref("Springfield"), "US"},
+		{int64(2), "admin", "Bob", "High St", nil, nil},
+		{int64(3), "admin", "Carol", nil, nil, nil},
+	})
+	expected := []person{
+		{1, Audit{"admin"}, "Alice", &Address{"Main St", Ref("Springfield"), &Country{"US"}}},
+		{2, Audit{"admin"}, "Bob", &Address{"High St", nil, nil}},
+		{3, Audit{"admin"}, "Carol", nil},
+	}
+	actual, err := pgxc.CollectRows(rows, pgxc.RowToStructByNameWith[person](mapper))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	rows.Reset()
+	addrs, err := pgxc.CollectRows(rows, pgxc.RowToAddrOfStructByPosWith[person](mapper))
+	assert.NoError(t, err)
+	assert.Equal(t, []*person{&expected[0], &expected[1], &expected[2]}, addrs)
+}
+
+type customer struct {
+	ID    int
+	email string
+	name  string
+}
+
+func (c *customer) SetEmail(email string) error {
+	if !strings.Contains(email, "@") {
+		return fmt.Errorf("invalid email %q", email)
+	}
+	c.email = email
+	return nil
+}
+
+func (c *customer) SetName(name string) {
+	c.name = name
+}
+
+// SetID is shadowed by the exported field ID.
+func (c *customer) SetID(id int) {
+	panic("SetID called")
+}
+
+func TestMapperSetters(t *testing.T) {
+	mapper := pgxc.NewMapper(pgxc.WithSetters())
+
+	rows := MakeMockRows("id,email,name", [][]any{
+		{1, "alice@example.com", "Alice"},
+		{2, "bob@example.com", "Bob"},
+	})
+	actual, err := pgxc.CollectRows(rows, pgxc.RowToAddrOfStructByNameWith[customer](mapper))
+	assert.NoError(t, err)
+	assert.Equal(t, []*customer{
+		{1, "alice@example.com", "Alice"},
+		{2, "bob@example.com", "Bob"},
+	}, actual)
+
+	rows = MakeMockRows("id,email", OneRow(1, "invalid"))
+	_, err = pgxc.CollectRows(rows, pgxc.RowToStructByNameLaxWith[customer](mapper))
+	assert.Error(t, err)
+
+	// Setters are optional, even for strict scanners.
+	rows = MakeMockRows("id,email", OneRow(1, "alice@example.com"))
+	expected := customer{ID: 1, email: "alice@example.com"}
+	checkScanOne(t, rows, pgxc.RowToStructByNameWith[customer](mapper), nil, expected)
+
+	// A field shadows the setter with its Go name, even if it's tagged with a column name.
+	type tagged struct {
+		customer
+		Email string `db:"email"`
+	}
+	rows = MakeMockRows("id,email,name", OneRow(1, "alice", "Alice"))
+	actualTagged, err := pgxc.CollectOneRow(rows, pgxc.RowToStructByNameWith[tagged](mapper))
+	assert.NoError(t, err)
+	assert.Equal(t, tagged{customer{ID: 1, name: "Alice"}, "alice"}, actualTagged)
+
+	// The fields of prefixed structs don't shadow setters.
+	type contact struct {
+		Email string
+	}
+	type withContact struct {
+		customer
+		Alt contact `db:"alt,prefix"`
+	}
+	rows = MakeMockRows("id,email,alt_email", OneRow(1, "alice@example.com", "al@example.com"))
+	expectedContact := withContact{
+		customer{ID: 1, email: "alice@example.com"},
+		contact{"al@example.com"},
+	}
+	checkScanOne(t, rows, pgxc.RowToStructByNameWith[withContact](mapper), nil, expectedContact)
+
+	// Without the option, setters are ignored.
+	rows = MakeMockRows("id,email,name", OneRow(1, "alice@example.com", "Alice"))
+	checkInitFails(t, rows, pgxc.RowToStructByName[customer], nil)
+}
+
+func TestMapperDiscardExtraColumns(t *testing.T) {
+	type person struct {
+		Name string
+		Age  int32
+	}
+	mapper := pgxc.NewMapper(pgxc.WithDiscardExtraColumns())
+
+	rows := MakeMockRows("name,age,email", OneRow("Alice", int32(30), "alice@example.com"))
+	expected := person{"Alice", 30}
+	checkScanOne(t, rows, pgxc.RowToStructByPosWith[person](mapper), nil, expected)
+	rows.Reset()
+	checkScanOne(t, rows, pgxc.RowToAddrOfStructByPosLaxWith[person](mapper), nil, &expected)
+
+	// Only lax scanners accept fewer columns.
+	rows = MakeMockRows("name", OneRow("Alice"))
+	checkInitFails(t, rows, pgxc.RowToStructByPosWith[person](mapper), nil)
+	rows.Reset()
+	checkScanOne(t, rows, pgxc.RowToStructByPosLaxWith[person](mapper), nil, person{Name: "Alice"})
+
+	// Without the option, extra columns are an error.
+	rows = MakeMockRows("name,age,email", OneRow("Alice", int32(30), "alice@example.com"))
+	checkInitFails(t, rows, pgxc.RowToStructByPosLax[person], nil)
+}
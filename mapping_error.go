@@ -0,0 +1,144 @@
+package pgx_collect
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	. "github.com/zolstein/pgx-collect/internal"
+)
+
+// MappingError is returned by the struct scanners which map columns by name when the columns of
+// a query don't match the fields of the struct. It lists every mismatch, rather than only the
+// first.
+type MappingError struct {
+	// Type is the struct type being scanned into.
+	Type reflect.Type
+	// MissingFields contains the required fields without a corresponding column,
+	// e.g. "Address.Street". For the lax scanners, only fields tagged "required" are required.
+	// Otherwise, all fields not tagged "optional" are required.
+	MissingFields []string
+	// UnmatchedColumns contains the columns without a corresponding field.
+	UnmatchedColumns []string
+	// Suggestions maps unmatched columns to the field without a corresponding column whose name
+	// is most similar, if it is similar enough that the column was likely meant to match it.
+	Suggestions map[string]string
+}
+
+// newMappingError returns the error for mismatch, or nil if the mismatch is allowed.
+func newMappingError(typ reflect.Type, mismatch Mismatch, lax bool) *MappingError {
+	var missingFields []string
+	for _, f := range mismatch.MissingFields {
+		if f.IsRequired(lax) {
+			missingFields = append(missingFields, f.Field)
+		}
+	}
+	if len(missingFields) == 0 && len(mismatch.UnmatchedColumns) == 0 {
+		return nil
+	}
+	err := &MappingError{
+		Type:             typ,
+		MissingFields:    missingFields,
+		UnmatchedColumns: mismatch.UnmatchedColumns,
+	}
+	for _, col := range mismatch.UnmatchedColumns {
+		if field, ok := suggestField(col, mismatch.MissingFields); ok {
+			if err.Suggestions == nil {
+				err.Suggestions = make(map[string]string)
+			}
+			err.Suggestions[col] = field
+		}
+	}
+	return err
+}
+
+func (e *MappingError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cannot map row to %v", e.Type)
+	sep := ": "
+	if len(e.MissingFields) > 0 {
+		b.WriteString(sep)
+		b.WriteString("no columns for fields ")
+		b.WriteString(strings.Join(e.MissingFields, ", "))
+		sep = "; "
+	}
+	if len(e.UnmatchedColumns) > 0 {
+		b.WriteString(sep)
+		b.WriteString("no fields for columns ")
+		for i, col := range e.UnmatchedColumns {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(col)
+			if field, ok := e.Suggestions[col]; ok {
+				fmt.Fprintf(&b, " (did you mean %s?)", field)
+			}
+		}
+	}
+	return b.String()
+}
+
+// suggestField returns the field whose name is most similar to col, if any is similar enough.
+// Names are compared case-insensitively, ignoring underscores.
+func suggestField(col string, fields []MissingField) (string, bool) {
+	col = normalizeName(col)
+	best, bestDist := "", -1
+	for _, f := range fields {
+		name := normalizeName(f.Column)
+		dist := editDistance(col, name)
+		// Allow roughly one edit per three characters.
+		if dist > (maxInt(len(col), len(name))+2)/3 {
+			continue
+		}
+		if bestDist == -1 || dist < bestDist {
+			best, bestDist = f.Field, dist
+		}
+	}
+	return best, bestDist != -1
+}
+
+func normalizeName(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, "_", ""))
+}
+
+// editDistance returns the number of insertions, deletions, substitutions and transpositions
+// of adjacent bytes needed to turn a into b.
+func editDistance(a, b string) int {
+	// prev2, prev and cur are the last three rows of the distance matrix.
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+// minInt and maxInt are equivalent to the min and max builtins, which require Go 1.21.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
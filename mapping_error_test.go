@@ -0,0 +1,60 @@
+package pgx_collect_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pgxc "github.com/zolstein/pgx-collect"
+	. "github.com/zolstein/pgx-collect/internal/testutils"
+)
+
+func TestMappingError(t *testing.T) {
+	type Address struct {
+		Street string
+		City   string
+	}
+	type person struct {
+		Name    string
+		Email   string
+		Address Address `db:"addr,prefix"`
+	}
+	typ := reflect.TypeOf(person{})
+
+	rows := MakeMockRows("nmae,addr_street,phone", OneRow("Alice", "Main St", "555"))
+	_, err := pgxc.CollectRows(rows, pgxc.RowToStructByName[person])
+	var mappingErr *pgxc.MappingError
+	assert.True(t, errors.As(err, &mappingErr))
+	assert.Equal(t, &pgxc.MappingError{
+		Type:             typ,
+		MissingFields:    []string{"Name", "Email", "Address.City"},
+		UnmatchedColumns: []string{"nmae", "phone"},
+		Suggestions:      map[string]string{"nmae": "Name"},
+	}, mappingErr)
+	assert.EqualError(
+		t,
+		err,
+		"cannot map row to pgx_collect_test.person: no columns for fields Name, Email, Address.City; "+
+			"no fields for columns nmae (did you mean Name?), phone",
+	)
+
+	// Lax scanners don't report missing fields.
+	rows = MakeMockRows("nmae,addr_street", OneRow("Alice", "Main St"))
+	_, err = pgxc.CollectRows(rows, pgxc.RowToStructByNameLax[person])
+	assert.True(t, errors.As(err, &mappingErr))
+	assert.Equal(t, &pgxc.MappingError{
+		Type:             typ,
+		UnmatchedColumns: []string{"nmae"},
+		Suggestions:      map[string]string{"nmae": "Name"},
+	}, mappingErr)
+
+	rows = MakeMockRows("name,email", OneRow("Alice", "alice@example.com"))
+	_, err = pgxc.CollectRows(rows, pgxc.RowToStructByName[person])
+	assert.EqualError(
+		t,
+		err,
+		"cannot map row to pgx_collect_test.person: no columns for fields Address.Street, Address.City",
+	)
+}
@@ -3,8 +3,11 @@ package pgx_collect
 import (
 	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 
 	. "github.com/zolstein/pgx-collect/internal"
 )
@@ -19,6 +22,33 @@ type Scanner[T any] interface {
 	ScanRowInto(receiver *T, rows pgx.Rows) error
 }
 
+// SelfScanner is implemented by types which scan themselves from a row. It allows types to do
+// any setup that depends on the columns of a query, such as resolving column indexes, once per
+// query rather than once per row.
+//
+// SelfScanner is detected on the types scanned by RowTo and the struct scanners, as well as on
+// the (exported) fields of scanned structs. A struct field which scans itself doesn't correspond
+// to any column, and is scanned from the whole row after the struct's other fields. When matching
+// by name, the columns it reads are unmatched unless other fields correspond to them, or it
+// implements SelfScannerColumns.
+type SelfScanner interface {
+	// InitializeScan is called once per query, on a zero value, before any rows are scanned.
+	InitializeScan(fldDescs []pgconn.FieldDescription) error
+	// ScanRowFrom scans the current row into the receiver. Before each row is scanned,
+	// the receiver is set to a copy of the value InitializeScan was called on.
+	ScanRowFrom(rows pgx.Rows) error
+}
+
+// SelfScannerColumns is implemented by SelfScanners which declare the columns they read. When
+// a struct field implements it, the struct scanners which map columns by name don't report those
+// columns as unmatched, even if no other field corresponds to them.
+type SelfScannerColumns interface {
+	SelfScanner
+	// ScannedColumns returns the names of the columns read by ScanRowFrom. It's called once per
+	// struct type, on a zero value.
+	ScannedColumns() []string
+}
+
 // RowSpec defines a specification for scanning rows into a given type.
 //
 // Note on the weird type definitions:
@@ -48,10 +78,67 @@ func (rs RowSpec[T]) Scanner() Scanner[T] {
 	return rs().fn()
 }
 
+// releaser is implemented by Scanners which can be reused once a collection function is done
+// with them. Scanners returned by RowSpec.Scanner are never released, since the caller owns them.
+type releaser interface {
+	// release resets the Scanner and returns it to its pool. The Scanner must not be used
+	// after calling release.
+	release()
+}
+
+// releaseScanner releases scanner if it supports being reused.
+func releaseScanner[T any](scanner Scanner[T]) {
+	if r, ok := scanner.(releaser); ok {
+		r.release()
+	}
+}
+
+// Map from reflect.Type -> *sync.Pool
+var scannerPools sync.Map
+
+// getPooled returns a *S from the pool of S values, allocating one if the pool is empty.
+func getPooled[S any]() *S {
+	return scannerPool[S]().Get().(*S)
+}
+
+// putPooled returns s to the pool of S values.
+func putPooled[S any](s *S) {
+	scannerPool[S]().Put(s)
+}
+
+func scannerPool[S any]() *sync.Pool {
+	typ := typeFor[S]()
+	if pool, ok := scannerPools.Load(typ); ok {
+		return pool.(*sync.Pool)
+	}
+	pool, _ := scannerPools.LoadOrStore(typ, &sync.Pool{
+		New: func() any { return new(S) },
+	})
+	return pool.(*sync.Pool)
+}
+
+// resizeScanTargets returns a slice of n scan targets, reusing scanTargets if possible.
+func resizeScanTargets(scanTargets []any, n int) []any {
+	if cap(scanTargets) < n {
+		return make([]any, n)
+	}
+	return scanTargets[:n]
+}
+
+// clearScanTargets clears scanTargets, so pooled Scanners don't keep receivers alive.
+func clearScanTargets(scanTargets []any) []any {
+	for i := range scanTargets {
+		scanTargets[i] = nil
+	}
+	return scanTargets[:0]
+}
+
 // AppendRows iterates through rows, scanning each row according to into,
 // and appending the results into a slice of T.
 func AppendRows[T any, S ~[]T](slice S, rows pgx.Rows, into RowSpec[T]) (S, error) {
-	return AppendRowsUsing(slice, rows, into().fn())
+	scanner := into().fn()
+	defer releaseScanner(scanner)
+	return AppendRowsUsing(slice, rows, scanner)
 }
 
 // AppendRowsUsing iterates through rows, scanning each row with the scanner,
@@ -107,7 +194,9 @@ func AppendRowsUsing[T any, S ~[]T](
 // CollectRows iterates through rows, scanning each row according to into,
 // and collecting the results into a slice of T.
 func CollectRows[T any](rows pgx.Rows, into RowSpec[T]) ([]T, error) {
-	return CollectRowsUsing(rows, into().fn())
+	scanner := into().fn()
+	defer releaseScanner(scanner)
+	return CollectRowsUsing(rows, scanner)
 }
 
 // CollectRowsUsing iterates through rows, scanning each row with the scanner,
@@ -120,7 +209,9 @@ func CollectRowsUsing[T any](rows pgx.Rows, scanner Scanner[T]) ([]T, error) {
 // If no rows are found returns an error where errors.Is(pgx.ErrNoRows) is true.
 // CollectOneRow is to CollectRows as QueryRow is to Query.
 func CollectOneRow[T any](rows pgx.Rows, into RowSpec[T]) (T, error) {
-	return CollectOneRowUsing(rows, into().fn())
+	scanner := into().fn()
+	defer releaseScanner(scanner)
+	return CollectOneRowUsing(rows, scanner)
 }
 
 // CollectOneRowUsing scans the first row in rows and returns the result.
@@ -166,7 +257,9 @@ func CollectOneRowUsing[T any](rows pgx.Rows, scanner Scanner[T]) (T, error) {
 //   - If no rows are found returns an error where errors.Is(pgx.ErrNoRows) is true.
 //   - If more than 1 row is found returns an error where errors.Is(ErrTooManyRows) is true.
 func CollectExactlyOneRow[T any](rows pgx.Rows, into RowSpec[T]) (T, error) {
-	return CollectExactlyOneRowUsing(rows, into().fn())
+	scanner := into().fn()
+	defer releaseScanner(scanner)
+	return CollectExactlyOneRowUsing(rows, scanner)
 }
 
 // CollectExactlyOneRowUsing scans the first row in rows and returns the result.
@@ -213,15 +306,77 @@ func CollectExactlyOneRowUsing[T any](rows pgx.Rows, scanner Scanner[T]) (T, err
 	return value, nil
 }
 
+// ScanOneRowInto scans the first row in rows into dst. Unlike CollectOneRow, dst isn't reset to
+// the zero value first, so fields of a struct which don't correspond to a column keep their
+// existing values. This is only useful with specs which allow that, e.g. RowToStructByNameLax,
+// which ScanOneRowIntoLax uses. Specs which scan into pointers, e.g. RowToAddrOfStructByName,
+// always replace *dst with a new value.
+// If no rows are found returns an error where errors.Is(pgx.ErrNoRows) is true.
+// If an error is returned, dst is unchanged, though structs it points to may have been modified.
+func ScanOneRowInto[T any](rows pgx.Rows, into RowSpec[T], dst *T) error {
+	scanner := into().fn()
+	defer releaseScanner(scanner)
+	return ScanOneRowIntoUsing(rows, scanner, dst)
+}
+
+// ScanOneRowIntoUsing scans the first row in rows into dst, like ScanOneRowInto.
+func ScanOneRowIntoUsing[T any](rows pgx.Rows, scanner Scanner[T], dst *T) error {
+	defer rows.Close()
+
+	err := scanner.Initialize(rows)
+	if err != nil {
+		return err
+	}
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+
+	// Scan into a copy, so dst is unchanged on error.
+	value := *dst
+	err = scanner.ScanRowInto(&value, rows)
+	if err != nil {
+		return err
+	}
+
+	rows.Close()
+
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	*dst = value
+	return nil
+}
+
+// ScanOneRowIntoLax scans the first row in rows into the struct dst, matching columns to fields by
+// name like RowToStructByNameLax. Only the fields with a corresponding column are overwritten,
+// and pointers to structs without any corresponding columns are left as they are, so this can
+// merge a subset of columns into an existing value, e.g. those returned by
+// UPDATE ... RETURNING.
+func ScanOneRowIntoLax[T any](rows pgx.Rows, dst *T) error {
+	return ScanOneRowInto(rows, RowToStructByNameLax[T], dst)
+}
+
 type simpleScanner[T any] struct {
 	scanTargets []any
+	// converter converts into T, if one is registered. src is the value it converts from.
+	converter *Converter
+	src       any
 }
 
 var _ Scanner[struct{}] = (*simpleScanner[struct{}])(nil)
 
 // newSimpleScanner returns a Scanner that scans a row into a T.
 func newSimpleScanner[T any]() Scanner[T] {
-	return &simpleScanner[T]{}
+	if isSelfScanner[T]() {
+		return newSelfScanningScanner[T]()
+	}
+	return getPooled[simpleScanner[T]]()
 }
 
 // newAddrOfSimpleScanner returns a Scanner that scans a row into a *T.
@@ -229,6 +384,28 @@ func newAddrOfSimpleScanner[T any]() Scanner[*T] {
 	return newAddrScanner(newSimpleScanner[T]())
 }
 
+// RegisterConverter registers fn to convert values into To, for all RowTo and struct scanners.
+// Rather than scanning columns directly into a To, they are scanned into a From, and then
+// converted with fn. E.g. to scan text columns into an enum type:
+//
+//	pgxc.RegisterConverter(func(s string) (Color, error) { return ParseColor(s) })
+//
+// The converter also applies to *To, unless a converter into *To is registered: NULL is scanned
+// as nil, and other values are converted into a newly allocated To.
+// Registering another converter into the same To replaces the existing one. Converters are
+// looked up when each query is initialized, so they should be registered before use, e.g. in an
+// init function.
+func RegisterConverter[From, To any](fn func(From) (To, error)) {
+	RegisterFieldConverter(typeFor[From](), typeFor[To](), func(src, dst any) error {
+		v, err := fn(*src.(*From))
+		if err != nil {
+			return err
+		}
+		*dst.(*To) = v
+		return nil
+	})
+}
+
 // RowTo scans a row into a T.
 func RowTo[T any]() rowSpecRes[T] {
 	return rowSpecRes[T]{fn: newSimpleScanner[T]}
@@ -240,42 +417,161 @@ func RowToAddrOf[T any]() rowSpecRes[*T] {
 }
 
 func (rs *simpleScanner[T]) Initialize(rows pgx.Rows) error {
+	rs.converter, _ = LookupConverter(typeFor[T]())
+	if rs.converter != nil {
+		rs.src = rs.converter.NewSource()
+	}
 	return nil
 }
 
 func (rs *simpleScanner[T]) ScanRowInto(receiver *T, rows pgx.Rows) error {
-	if rs.scanTargets == nil {
-		rs.scanTargets = make([]any, 1)
+	if len(rs.scanTargets) != 1 {
+		rs.scanTargets = resizeScanTargets(rs.scanTargets, 1)
+	}
+	if rs.converter == nil {
+		rs.scanTargets[0] = receiver
+		return rows.Scan(rs.scanTargets...)
+	}
+	rs.scanTargets[0] = rs.src
+	if err := rows.Scan(rs.scanTargets...); err != nil {
+		return err
 	}
-	rs.scanTargets[0] = receiver
-	return rows.Scan(rs.scanTargets...)
+	return rs.converter.Convert(rs.src, receiver)
+}
+
+func (rs *simpleScanner[T]) reset() {
+	rs.scanTargets = clearScanTargets(rs.scanTargets)
+	rs.converter = nil
+	rs.src = nil
+}
+
+func (rs *simpleScanner[T]) release() {
+	rs.reset()
+	putPooled(rs)
+}
+
+// nullZeroScanner is a simpleScanner which scans NULL as the zero value.
+type nullZeroScanner[T any] struct {
+	simpleScanner[T]
+}
+
+var _ Scanner[struct{}] = (*nullZeroScanner[struct{}])(nil)
+
+// newNullZeroScanner returns a Scanner that scans a row into a T, or the zero value if it is NULL.
+func newNullZeroScanner[T any]() Scanner[T] {
+	if isSelfScanner[T]() {
+		return newSelfScanningScanner[T]()
+	}
+	return getPooled[nullZeroScanner[T]]()
+}
+
+// newAddrOfNullZeroScanner returns a Scanner that scans a row into a *T, or a pointer to the zero
+// value if it is NULL.
+func newAddrOfNullZeroScanner[T any]() Scanner[*T] {
+	return newAddrScanner(newNullZeroScanner[T]())
+}
+
+// RowToNullZero scans a row into a T. Unlike RowTo, NULL is scanned as the zero value of T,
+// even if T can't represent NULL.
+func RowToNullZero[T any]() rowSpecRes[T] {
+	return rowSpecRes[T]{fn: newNullZeroScanner[T]}
+}
+
+// RowToAddrOfNullZero scans a row into a *T. Unlike RowToAddrOf, NULL is scanned as a pointer to
+// the zero value of T, even if T can't represent NULL.
+func RowToAddrOfNullZero[T any]() rowSpecRes[*T] {
+	return rowSpecRes[*T]{fn: newAddrOfNullZeroScanner[T]}
+}
+
+func (rs *nullZeroScanner[T]) ScanRowInto(receiver *T, rows pgx.Rows) error {
+	if rawValues := rows.RawValues(); len(rawValues) == 1 && rawValues[0] == nil {
+		var zero T
+		*receiver = zero
+		return nil
+	}
+	return rs.simpleScanner.ScanRowInto(receiver, rows)
+}
+
+func (rs *nullZeroScanner[T]) release() {
+	rs.reset()
+	putPooled(rs)
 }
 
 type positionalStructScanner[T any] struct {
 	structScanner[T]
 }
 
-var _ Scanner[struct{}] = (*positionalStructScanner[struct{}])(nil)
+type strictPositionalStructScanner[T any] struct {
+	positionalStructScanner[T]
+}
+
+type laxPositionalStructScanner[T any] struct {
+	positionalStructScanner[T]
+}
+
+var (
+	_ Scanner[struct{}] = (*strictPositionalStructScanner[struct{}])(nil)
+	_ Scanner[struct{}] = (*laxPositionalStructScanner[struct{}])(nil)
+)
 
 // newPositionalStructScanner returns a Scanner that scans a T from a row.
 // T must be a struct. T must have the same number of public fields as row has fields.
-// The row and T fields will be matched by position.
+// The row and T fields will be matched by position, in declaration order unless the fields
+// are tagged with positions, e.g. `db:",pos=0"`.
 // If the "db" struct tag is "-" then the field will be ignored.
 func newPositionalStructScanner[T any]() Scanner[T] {
-	return &positionalStructScanner[T]{}
+	return newPositionalStructScannerWith[T](DefaultFieldMapper)
+}
+
+func newPositionalStructScannerWith[T any](mapper *FieldMapper) Scanner[T] {
+	if isSelfScanner[T]() {
+		return newSelfScanningScanner[T]()
+	}
+	rs := getPooled[strictPositionalStructScanner[T]]()
+	rs.mapper = mapper
+	return rs
+}
+
+// newLaxPositionalStructScanner returns a Scanner that scans a T from a row.
+// T must be a struct. T must have at least as many public fields as row has fields.
+// The row fields will be matched by position to a prefix of the T fields, in declaration order
+// unless the fields are tagged with positions, e.g. `db:",pos=0"`.
+// If the "db" struct tag is "-" then the field will be ignored.
+func newLaxPositionalStructScanner[T any]() Scanner[T] {
+	return newLaxPositionalStructScannerWith[T](DefaultFieldMapper)
+}
+
+func newLaxPositionalStructScannerWith[T any](mapper *FieldMapper) Scanner[T] {
+	if isSelfScanner[T]() {
+		return newSelfScanningScanner[T]()
+	}
+	rs := getPooled[laxPositionalStructScanner[T]]()
+	rs.mapper = mapper
+	return rs
 }
 
 // newAddrOfPositionalStructScanner returns a Scanner that scans a *T from a row.
 // T must be a struct. T must have the same number of public fields as row has fields.
-// The row and T fields will be matched by position.
+// The row and T fields will be matched by position, in declaration order unless the fields
+// are tagged with positions, e.g. `db:",pos=0"`.
 // If the "db" struct tag is "-" then the field will be ignored.
 func newAddrOfPositionalStructScanner[T any]() Scanner[*T] {
 	return newAddrScanner[T](newPositionalStructScanner[T]())
 }
 
+// newAddrOfLaxPositionalStructScanner returns a Scanner that scans a *T from a row.
+// T must be a struct. T must have at least as many public fields as row has fields.
+// The row fields will be matched by position to a prefix of the T fields, in declaration order
+// unless the fields are tagged with positions, e.g. `db:",pos=0"`.
+// If the "db" struct tag is "-" then the field will be ignored.
+func newAddrOfLaxPositionalStructScanner[T any]() Scanner[*T] {
+	return newAddrScanner[T](newLaxPositionalStructScanner[T]())
+}
+
 // RowToStructByPos scans a row into a T.
 // T must be a struct. T must have the same number of public fields as row has fields.
-// The row and T fields will be matched by position.
+// The row and T fields will be matched by position, in declaration order unless the fields
+// are tagged with positions, e.g. `db:",pos=0"`.
 // If the "db" struct tag is "-" then the field will be ignored.
 func RowToStructByPos[T any]() rowSpecRes[T] {
 	return rowSpecRes[T]{fn: newPositionalStructScanner[T]}
@@ -283,21 +579,63 @@ func RowToStructByPos[T any]() rowSpecRes[T] {
 
 // RowToAddrOfStructByPos scans a row into a *T.
 // T must be a struct. T must have the same number of public fields as row has fields.
-// The row and T fields will be matched by position.
+// The row and T fields will be matched by position, in declaration order unless the fields
+// are tagged with positions, e.g. `db:",pos=0"`.
 // If the "db" struct tag is "-" then the field will be ignored.
 func RowToAddrOfStructByPos[T any]() rowSpecRes[*T] {
 	return rowSpecRes[*T]{fn: newAddrOfPositionalStructScanner[T]}
 }
 
-func (rs *positionalStructScanner[T]) Initialize(rows pgx.Rows) error {
+// RowToStructByPosLax scans a row into a T.
+// T must be a struct. T must have at least as many public fields as row has fields.
+// The row fields will be matched by position to a prefix of the T fields, in declaration order
+// unless the fields are tagged with positions, e.g. `db:",pos=0"`. The remaining T fields are
+// left unchanged.
+// If the "db" struct tag is "-" then the field will be ignored.
+func RowToStructByPosLax[T any]() rowSpecRes[T] {
+	return rowSpecRes[T]{fn: newLaxPositionalStructScanner[T]}
+}
+
+// RowToAddrOfStructByPosLax scans a row into a *T.
+// T must be a struct. T must have at least as many public fields as row has fields.
+// The row fields will be matched by position to a prefix of the T fields, in declaration order
+// unless the fields are tagged with positions, e.g. `db:",pos=0"`. The remaining T fields are
+// left unchanged.
+// If the "db" struct tag is "-" then the field will be ignored.
+func RowToAddrOfStructByPosLax[T any]() rowSpecRes[*T] {
+	return rowSpecRes[*T]{fn: newAddrOfLaxPositionalStructScanner[T]}
+}
+
+func (rs *strictPositionalStructScanner[T]) Initialize(rows pgx.Rows) error {
+	return rs.initialize(rows, false)
+}
+
+func (rs *laxPositionalStructScanner[T]) Initialize(rows pgx.Rows) error {
+	return rs.initialize(rows, true)
+}
+
+func (rs *strictPositionalStructScanner[T]) release() {
+	rs.reset()
+	putPooled(rs)
+}
+
+func (rs *laxPositionalStructScanner[T]) release() {
+	rs.reset()
+	putPooled(rs)
+}
+
+func (rs *positionalStructScanner[T]) initialize(rows pgx.Rows, lax bool) error {
 	typ := typeFor[T]()
 	if typ.Kind() != reflect.Struct {
 		return fmt.Errorf("generic type '%s' is not a struct", typ.Name())
 	}
 	fldDescs := rows.FieldDescriptions()
 	var err error
-	rs.scanFields, err = GetStructRowFieldsByPos(typ, fldDescs)
-	return err
+	rs.scanFields, err = rs.mapper.GetStructRowFieldsByPos(typ, fldDescs, lax)
+	if err != nil {
+		return err
+	}
+	return rs.initializeScanState(fldDescs)
 }
 
 type namedStructScanner[T any] struct {
@@ -323,7 +661,16 @@ var (
 // The database column name can be overridden with a "db" struct tag.
 // If the "db" struct tag is "-" then the field will be ignored.
 func newNamedStructScanner[T any]() Scanner[T] {
-	return &strictNamedStructScanner[T]{}
+	return newNamedStructScannerWith[T](DefaultFieldMapper)
+}
+
+func newNamedStructScannerWith[T any](mapper *FieldMapper) Scanner[T] {
+	if isSelfScanner[T]() {
+		return newSelfScanningScanner[T]()
+	}
+	rs := getPooled[strictNamedStructScanner[T]]()
+	rs.mapper = mapper
+	return rs
 }
 
 // newLaxNamedStructScanner returns a Scanner that scans a row into a T.
@@ -332,7 +679,16 @@ func newNamedStructScanner[T any]() Scanner[T] {
 // The database column name can be overridden with a "db" struct tag.
 // If the "db" struct tag is "-" then the field will be ignored.
 func newLaxNamedStructScanner[T any]() Scanner[T] {
-	return &laxNamedStructScanner[T]{}
+	return newLaxNamedStructScannerWith[T](DefaultFieldMapper)
+}
+
+func newLaxNamedStructScannerWith[T any](mapper *FieldMapper) Scanner[T] {
+	if isSelfScanner[T]() {
+		return newSelfScanningScanner[T]()
+	}
+	rs := getPooled[laxNamedStructScanner[T]]()
+	rs.mapper = mapper
+	return rs
 }
 
 // newAddrOfNamedStructScanner returns a Scanner that scans a row into a *T.
@@ -358,6 +714,7 @@ func newAddrOfLaxNamedStructScanner[T any]() Scanner[*T] {
 // The row and T fields will be matched by name. The match is case-insensitive.
 // The database column name can be overridden with a "db" struct tag.
 // If the "db" struct tag is "-" then the field will be ignored.
+// It is an error if two fields match the same column, or a field matches two columns.
 func RowToStructByName[T any]() rowSpecRes[T] {
 	return rowSpecRes[T]{fn: newNamedStructScanner[T]}
 }
@@ -397,22 +754,32 @@ func (rs *laxNamedStructScanner[T]) Initialize(rows pgx.Rows) error {
 	return rs.initialize(rows, true)
 }
 
+func (rs *strictNamedStructScanner[T]) release() {
+	rs.reset()
+	putPooled(rs)
+}
+
+func (rs *laxNamedStructScanner[T]) release() {
+	rs.reset()
+	putPooled(rs)
+}
+
 func (rs *namedStructScanner[T]) initialize(rows pgx.Rows, lax bool) error {
 	typ := typeFor[T]()
 	if typ.Kind() != reflect.Struct {
 		return fmt.Errorf("generic type '%s' is not a struct", typ.Name())
 	}
 	fldDescs := rows.FieldDescriptions()
-	var missingField string
+	var mismatch Mismatch
 	var err error
-	rs.scanFields, missingField, err = GetStructRowFieldsByName(typ, fldDescs)
+	rs.scanFields, mismatch, err = rs.mapper.GetStructRowFieldsByName(typ, fldDescs)
 	if err != nil {
 		return err
-	} else if !lax && missingField != "" {
-		return fmt.Errorf("cannot find field %s in returned row", missingField)
+	} else if mappingErr := newMappingError(typ, mismatch, lax); mappingErr != nil {
+		return mappingErr
 	}
 
-	return nil
+	return rs.initializeScanState(fldDescs)
 }
 
 func typeFor[T any]() reflect.Type {
@@ -423,17 +790,67 @@ func typeFor[T any]() reflect.Type {
 
 // structScanner encapsulates the logic to scan a row into fields of a struct.
 type structScanner[T any] struct {
+	mapper      *FieldMapper
 	scanFields  StructRowFields
 	scanTargets []any
+	scanState   ScanState
+}
+
+func (rs *structScanner[T]) initializeScanState(fldDescs []pgconn.FieldDescription) error {
+	var err error
+	rs.scanState, err = rs.scanFields.NewScanState(fldDescs)
+	return err
+}
+
+// reset clears the per-query state of the structScanner, so it can be reused.
+func (rs *structScanner[T]) reset() {
+	rs.mapper = nil
+	rs.scanFields = StructRowFields{}
+	rs.scanTargets = clearScanTargets(rs.scanTargets)
+	rs.scanState = ScanState{}
 }
 
 func (rs *structScanner[T]) ScanRowInto(receiver *T, rows pgx.Rows) error {
-	if rs.scanTargets == nil {
-		rs.scanTargets = make([]any, rs.scanFields.NumFields())
+	if len(rs.scanTargets) != rs.scanFields.NumFields() {
+		rs.scanTargets = resizeScanTargets(rs.scanTargets, rs.scanFields.NumFields())
 	}
 	r := ReceiverFromPointer(receiver)
-	rs.scanFields.Populate(r, rs.scanTargets)
-	return rows.Scan(rs.scanTargets...)
+	rs.scanFields.Populate(r, rows, &rs.scanState, rs.scanTargets)
+	if err := rows.Scan(rs.scanTargets...); err != nil {
+		return err
+	}
+	return rs.scanFields.Complete(r, rows, &rs.scanState)
+}
+
+// selfScanningScanner scans a row into a T which implements SelfScanner.
+type selfScanningScanner[T any] struct {
+	proto T
+}
+
+var _ Scanner[struct{}] = (*selfScanningScanner[struct{}])(nil)
+
+func isSelfScanner[T any]() bool {
+	_, ok := any((*T)(nil)).(SelfScanner)
+	return ok
+}
+
+// newSelfScanningScanner returns a Scanner that delegates to T's implementation of SelfScanner.
+func newSelfScanningScanner[T any]() Scanner[T] {
+	return &selfScanningScanner[T]{}
+}
+
+func (rs *selfScanningScanner[T]) Initialize(rows pgx.Rows) error {
+	var proto T
+	if err := any(&proto).(SelfScanner).InitializeScan(rows.FieldDescriptions()); err != nil {
+		return err
+	}
+	rs.proto = proto
+	return nil
+}
+
+func (rs *selfScanningScanner[T]) ScanRowInto(receiver *T, rows pgx.Rows) error {
+	*receiver = rs.proto
+	return any(receiver).(SelfScanner).ScanRowFrom(rows)
 }
 
 // addrScannerInfo wraps a Scanner[T] into a Scanner[*T].
@@ -457,6 +874,10 @@ func (rs *addrScanner[T]) ScanRowInto(receiver **T, rows pgx.Rows) error {
 	return rs.wrapped.ScanRowInto(*receiver, rows)
 }
 
+func (rs *addrScanner[T]) release() {
+	releaseScanner(rs.wrapped)
+}
+
 type mapScanner struct{}
 
 var _ Scanner[map[string]any] = (*mapScanner)(nil)
@@ -527,3 +948,100 @@ func (rs adapterScanner[T]) ScanRowInto(receiver *T, rows pgx.Rows) error {
 	*receiver, err = rs(rows)
 	return err
 }
+
+// ToRowToFunc converts a RowSpec into a pgx.RowToFunc. This is the inverse of Adapt.
+//
+// This allows native pgx-collect RowSpecs to be used with code that only accepts a
+// pgx.RowToFunc, such as pgx.CollectRows:
+//
+//	pgx.CollectRows(rows, pgxc.ToRowToFunc(pgxc.RowToStructByName[Record]))
+//
+// The returned function caches the Scanner it last initialized, along with the field descriptions
+// it was initialized for, so repeated calls within one query, or across queries returning the same
+// columns, don't initialize a new Scanner. It may be shared between goroutines; concurrent calls
+// each use their own Scanner, though only one is cached.
+// This is less efficient than passing the RowSpec to a pgx-collect function directly,
+// so it's only recommended during a migration process.
+func ToRowToFunc[T any](into RowSpec[T]) pgx.RowToFunc[T] {
+	var cached atomic.Pointer[rowToFuncEntry[T]]
+	return func(row pgx.CollectableRow) (T, error) {
+		var zero T
+		rows := collectableRowsFrom(row)
+		fldDescs := rows.FieldDescriptions()
+		// Taking the entry ensures no other call uses its scanner concurrently.
+		entry := cached.Swap(nil)
+		if entry == nil || !fieldDescriptionsEqual(entry.fldDescs, fldDescs) {
+			if entry != nil {
+				releaseScanner(entry.scanner)
+			}
+			// pgconn reuses the backing array of the field descriptions between queries, so they
+			// must be copied rather than compared by identity.
+			entry = &rowToFuncEntry[T]{
+				fldDescs: append([]pgconn.FieldDescription(nil), fldDescs...),
+				scanner:  into().fn(),
+			}
+			if err := entry.scanner.Initialize(rows); err != nil {
+				return zero, err
+			}
+		}
+		err := entry.scanner.ScanRowInto(&entry.value, rows)
+		value := entry.value
+		entry.value = zero
+		cached.Store(entry)
+		return value, err
+	}
+}
+
+// rowToFuncEntry is a Scanner initialized for rows with the given field descriptions.
+type rowToFuncEntry[T any] struct {
+	fldDescs []pgconn.FieldDescription
+	scanner  Scanner[T]
+	// value is scanned into, to avoid allocating a receiver for each row. It's reset to the zero
+	// value after each row, so it doesn't keep the row's values alive.
+	value T
+}
+
+func fieldDescriptionsEqual(a, b []pgconn.FieldDescription) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// collectableRowsFrom returns row as a pgx.Rows, so it can be passed to a Scanner.
+func collectableRowsFrom(row pgx.CollectableRow) pgx.Rows {
+	if rows, ok := row.(pgx.Rows); ok {
+		return rows
+	}
+	return collectableRows{row}
+}
+
+// collectableRows adapts a pgx.CollectableRow into a pgx.Rows positioned on that row.
+type collectableRows struct {
+	pgx.CollectableRow
+}
+
+var _ pgx.Rows = collectableRows{}
+
+func (collectableRows) Close() {}
+
+func (collectableRows) Err() error {
+	return nil
+}
+
+func (collectableRows) CommandTag() pgconn.CommandTag {
+	return pgconn.CommandTag{}
+}
+
+func (collectableRows) Next() bool {
+	return false
+}
+
+func (collectableRows) Conn() *pgx.Conn {
+	return nil
+}
@@ -1,10 +1,17 @@
 package pgx_collect_test
 
 import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
 
 	pgxc "github.com/zolstein/pgx-collect"
@@ -390,8 +397,14 @@ func TestPositionalStructRowScanner(t *testing.T) {
 			Age int32
 		}
 
+		// pgx doesn't support embedded pointers.
 		rows := MakeMockRows("first_name,last_name,age", OneRow("John", "Smith", int32(25)))
-		checkStructByPosFail[person](t, rows)
+		expected := person{&Name{First: "John", Last: "Smith"}, 25}
+		checkScanOne(t, rows, pgxc.RowToStructByPos[person], nil, expected)
+
+		rows = MakeMockRows("first_name,last_name,age", OneRow(nil, nil, int32(25)))
+		expected = person{Age: 25}
+		checkScanOne(t, rows, pgxc.RowToStructByPos[person], nil, expected)
 	}
 }
 
@@ -417,6 +430,97 @@ func checkStructByPosFail[T any](t *testing.T, rows *MockRows) {
 	checkInitFails(t, rows, rsAddr, pgxRowToAddr)
 }
 
+func TestPosTaggedStructRowScanner(t *testing.T) {
+	type Audit struct {
+		CreatedAt string `db:",pos=3"`
+	}
+	type person struct {
+		Age int32 `db:",pos=2"`
+		Audit
+		ID   int64  `db:"id,pos=0"`
+		Name string `db:",pos=1"`
+	}
+
+	rows := MakeMockRows("id,name,age,created_at", OneRow(int64(1), "Alice", int32(30), "today"))
+	expected := person{Age: 30, Audit: Audit{CreatedAt: "today"}, ID: 1, Name: "Alice"}
+	checkScanOne(t, rows, pgxc.RowToStructByPos[person], nil, expected)
+	rows.Reset()
+	checkScanOne(t, rows, pgxc.RowToAddrOfStructByPos[person], nil, &expected)
+
+	// Positions don't affect mapping by name.
+	rows = MakeMockRows("name,created_at,age,id", OneRow("Alice", "today", int32(30), int64(1)))
+	checkScanOne(t, rows, pgxc.RowToStructByName[person], nil, expected)
+
+	rows = MakeMockRows("a,b", OneRow(1, 2))
+	{
+		type partial struct {
+			A int `db:",pos=1"`
+			B int
+		}
+		_, err := pgxc.CollectRows(rows, pgxc.RowToStructByPos[partial])
+		assert.EqualError(t, err, "struct field B has no pos, but other fields do")
+	}
+	{
+		type duplicate struct {
+			A int `db:",pos=0"`
+			B int `db:",pos=0"`
+		}
+		_, err := pgxc.CollectRows(rows, pgxc.RowToStructByPos[duplicate])
+		assert.EqualError(t, err, "struct fields A and B both have pos 0")
+	}
+	{
+		type gap struct {
+			A int `db:",pos=0"`
+			B int `db:",pos=2"`
+		}
+		_, err := pgxc.CollectRows(rows, pgxc.RowToStructByPos[gap])
+		assert.EqualError(t, err, "struct field B has pos 2, but struct has only 2 fields")
+	}
+	{
+		type invalid struct {
+			A int `db:",pos=0"`
+			B int `db:",pos=one"`
+		}
+		_, err := pgxc.CollectRows(rows, pgxc.RowToStructByPos[invalid])
+		assert.EqualError(t, err, "struct field B has an invalid pos")
+	}
+}
+
+func TestLaxPositionalStructRowScanner(t *testing.T) {
+	type Name struct {
+		First string
+		Last  string
+	}
+	type person struct {
+		*Name
+		Age   int32
+		Email string
+	}
+
+	rows := MakeMockRows("first,last,age", [][]any{
+		{"John", "Smith", int32(25)},
+		{nil, nil, int32(30)},
+	})
+	actual, err := pgxc.CollectRows(rows, pgxc.RowToStructByPosLax[person])
+	assert.NoError(t, err)
+	assert.Equal(t, []person{{Name: &Name{"John", "Smith"}, Age: 25}, {Age: 30}}, actual)
+
+	rows = MakeMockRows("first", OneRow("John"))
+	expected := person{Name: &Name{First: "John"}}
+	checkScanOne(t, rows, pgxc.RowToStructByPosLax[person], nil, expected)
+	rows.Reset()
+	checkScanOne(t, rows, pgxc.RowToAddrOfStructByPosLax[person], nil, &expected)
+
+	rows = MakeMockRows("first,last,age,email", OneRow("John", "Smith", int32(25), "john@example.com"))
+	expected = person{&Name{"John", "Smith"}, 25, "john@example.com"}
+	checkScanOne(t, rows, pgxc.RowToStructByPosLax[person], nil, expected)
+
+	// Extra columns are still an error.
+	rows = MakeMockRows("first,last,age,email,phone", OneRow("John", "Smith", int32(25), "", ""))
+	_, err = pgxc.CollectRows(rows, pgxc.RowToStructByPosLax[person])
+	assert.EqualError(t, err, "got 5 values, but dst struct has only 4 fields")
+}
+
 func TestNamedStructRowScanner(t *testing.T) {
 	{
 		const accID = "d5e49d3f"
@@ -814,6 +918,10 @@ func checkScanOne[T any](
 	err = rs.ScanRowInto(&val, rows)
 	assert.NoError(t, err)
 	assert.Equal(t, expected, val)
+	if rowTo == nil {
+		// pgx doesn't support this case.
+		return
+	}
 	// Also check that we match vanilla pgx.
 	pgxVal, pgxErr := rowTo(rows)
 	assert.NoError(t, pgxErr)
@@ -830,8 +938,890 @@ func checkInitFails[T any](
 	rs := rowSpec.Scanner()
 	err := rs.Initialize(rows)
 	assert.Error(t, err)
+	if rowTo == nil {
+		// pgx doesn't support this case.
+		return
+	}
 	rows.Next()
 	_, pgxErr := rowTo(rows)
 	assert.Error(t, pgxErr)
-	assert.Equal(t, err, pgxErr)
+	var mappingErr *pgxc.MappingError
+	if !errors.As(err, &mappingErr) {
+		// MappingErrors list all mismatches, so differ from pgx's errors.
+		assert.Equal(t, err, pgxErr)
+	}
+}
+
+func TestToRowToFunc(t *testing.T) {
+	type person struct {
+		Name string
+		Age  int32
+	}
+
+	rowTo := pgxc.ToRowToFunc(pgxc.RowToStructByName[person])
+	{
+		rows := MakeMockRows("name,age", [][]any{
+			{"Alice", int32(30)},
+			{"Bob", int32(25)},
+		})
+		expected := []person{{"Alice", 30}, {"Bob", 25}}
+		actual, err := pgx.CollectRows(rows, rowTo)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+	{
+		rows := MakeMockRows("age,name", OneRow(int32(40), "Carol"))
+		actual, err := pgx.CollectOneRow(rows, rowTo)
+		assert.NoError(t, err)
+		assert.Equal(t, person{"Carol", 40}, actual)
+	}
+	{
+		rows := MakeMockRows("name", OneRow("Dave"))
+		_, err := pgx.CollectOneRow(rows, rowTo)
+		assert.Error(t, err)
+	}
+	{
+		// The scanner is initialized once for rows with the same columns.
+		rowTo := pgxc.ToRowToFunc(pgxc.RowTo[initCountingName])
+		initCountingNameInits = 0
+		rows := MakeMockRows("name", [][]any{{"Alice"}, {"Bob"}, {"Carol"}})
+		actual, err := pgx.CollectRows(rows, rowTo)
+		assert.NoError(t, err)
+		assert.Equal(t, []initCountingName{{"Alice"}, {"Bob"}, {"Carol"}}, actual)
+		assert.Equal(t, 1, initCountingNameInits)
+
+		// Interleaved rows of result sets with the same columns share the scanner.
+		rows1 := MakeMockRows("name", [][]any{{"Dave"}, {"Eve"}, {"Frank"}})
+		rows2 := MakeMockRows("name", [][]any{{"Grace"}, {"Heidi"}, {"Ivan"}})
+		var names []string
+		for rows1.Next() && rows2.Next() {
+			for _, r := range []pgx.Rows{rows1, rows2} {
+				v, err := rowTo(r)
+				assert.NoError(t, err)
+				names = append(names, v.Name)
+			}
+		}
+		assert.Equal(t, []string{"Dave", "Grace", "Eve", "Heidi", "Frank", "Ivan"}, names)
+		assert.Equal(t, 1, initCountingNameInits)
+
+		// Rows with different columns initialize a new scanner, which replaces the cached one.
+		rows = MakeMockRows("name,age", [][]any{{"Judy", int32(30)}})
+		_, err = pgx.CollectRows(rows, rowTo)
+		assert.Error(t, err)
+		assert.Equal(t, 2, initCountingNameInits)
+
+		rows = MakeMockRows("name", [][]any{{"Mallory"}})
+		_, err = pgx.CollectRows(rows, rowTo)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, initCountingNameInits)
+	}
+}
+
+// initCountingNameInits counts the calls to initCountingName.InitializeScan.
+var initCountingNameInits int
+
+// initCountingName scans a single column, counting how often it's initialized.
+type initCountingName struct {
+	Name string
+}
+
+func (s *initCountingName) InitializeScan(fldDescs []pgconn.FieldDescription) error {
+	initCountingNameInits++
+	return nil
+}
+
+func (s *initCountingName) ScanRowFrom(rows pgx.Rows) error {
+	return rows.Scan(&s.Name)
+}
+
+// selfScannedName scans the "name" column of a row, resolving its position once per query.
+type selfScannedName struct {
+	numCols int
+	nameIdx int
+	inits   int
+	Name    string
+}
+
+var _ pgxc.SelfScannerColumns = (*selfScannedName)(nil)
+
+func (s *selfScannedName) InitializeScan(fldDescs []pgconn.FieldDescription) error {
+	s.inits++
+	s.numCols = len(fldDescs)
+	for i := range fldDescs {
+		if fldDescs[i].Name == "name" {
+			s.nameIdx = i
+			return nil
+		}
+	}
+	return fmt.Errorf("missing name column")
+}
+
+func (s *selfScannedName) ScannedColumns() []string {
+	return []string{"name"}
+}
+
+func (s *selfScannedName) ScanRowFrom(rows pgx.Rows) error {
+	targets := make([]any, s.numCols)
+	targets[s.nameIdx] = &s.Name
+	return rows.Scan(targets...)
+}
+
+func TestSelfScanner(t *testing.T) {
+	makeRows := func() *MockRows {
+		return MakeMockRows("id,name", [][]any{{1, "Alice"}, {2, "Bob"}})
+	}
+	expectedNames := []selfScannedName{
+		{numCols: 2, nameIdx: 1, inits: 1, Name: "Alice"},
+		{numCols: 2, nameIdx: 1, inits: 1, Name: "Bob"},
+	}
+
+	t.Run("row-to", func(t *testing.T) {
+		actual, err := pgxc.CollectRows(makeRows(), pgxc.RowTo[selfScannedName])
+		assert.NoError(t, err)
+		assert.Equal(t, expectedNames, actual)
+	})
+
+	t.Run("struct-by-name", func(t *testing.T) {
+		actual, err := pgxc.CollectRows(makeRows(), pgxc.RowToStructByName[selfScannedName])
+		assert.NoError(t, err)
+		assert.Equal(t, expectedNames, actual)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		type record struct {
+			ID    int
+			Extra selfScannedName
+		}
+		expected := []record{
+			{ID: 1, Extra: expectedNames[0]},
+			{ID: 2, Extra: expectedNames[1]},
+		}
+		actual, err := pgxc.CollectRows(makeRows(), pgxc.RowToStructByName[record])
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+
+		actual, err = pgxc.CollectRows(makeRows(), pgxc.RowToStructByNameLax[record])
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+
+		// Extra columns are still unmatched.
+		rows := MakeMockRows("id,name,extra", OneRow(1, "Alice", 2))
+		_, err = pgxc.CollectRows(rows, pgxc.RowToStructByName[record])
+		assert.EqualError(t, err, "cannot map row to pgx_collect_test.record: no fields for columns extra")
+
+		type named struct {
+			ID    int
+			Name  string
+			Extra selfScannedName
+		}
+		actualNamed, err := pgxc.CollectRows(makeRows(), pgxc.RowToStructByName[named])
+		assert.NoError(t, err)
+		assert.Equal(t, []named{
+			{ID: 1, Name: "Alice", Extra: expectedNames[0]},
+			{ID: 2, Name: "Bob", Extra: expectedNames[1]},
+		}, actualNamed)
+
+		// Columns read by the self-scanning field aren't collected into the rest field.
+		type withRest struct {
+			ID    int
+			Extra selfScannedName
+			Rest  map[string]any `db:",rest"`
+		}
+		rows = MakeMockRows("id,name,extra", OneRow(1, "Alice", 2))
+		expectedRest := withRest{
+			ID:    1,
+			Extra: selfScannedName{numCols: 3, nameIdx: 1, inits: 1, Name: "Alice"},
+			Rest:  map[string]any{"extra": 2},
+		}
+		checkScanOne(t, rows, pgxc.RowToStructByName[withRest], nil, expectedRest)
+	})
+
+	t.Run("initialize-fails", func(t *testing.T) {
+		rows := MakeMockRows("id", OneRow(1))
+		_, err := pgxc.CollectRows(rows, pgxc.RowTo[selfScannedName])
+		assert.Error(t, err)
+	})
+}
+
+func TestPooledScannerReuse(t *testing.T) {
+	type person struct {
+		First string
+		Last  string
+		Age   int32
+	}
+
+	for i := 0; i < 10; i++ {
+		rows := MakeMockRows("first,last,age", OneRow("John", "Smith", int32(25)))
+		actual, err := pgxc.CollectOneRow(rows, pgxc.RowToStructByNameLax[person])
+		assert.NoError(t, err)
+		assert.Equal(t, person{"John", "Smith", 25}, actual)
+
+		rows = MakeMockRows("first", OneRow("Jane"))
+		actual, err = pgxc.CollectOneRow(rows, pgxc.RowToStructByNameLax[person])
+		assert.NoError(t, err)
+		assert.Equal(t, person{First: "Jane"}, actual)
+
+		rows = MakeMockRows("first,last,age", OneRow("Jane", "Doe", int32(30)))
+		addr, err := pgxc.CollectRows(rows, pgxc.RowToAddrOfStructByPos[person])
+		assert.NoError(t, err)
+		assert.Equal(t, []*person{{"Jane", "Doe", 30}}, addr)
+
+		rows = MakeMockRows("id", OneRow(i))
+		id, err := pgxc.CollectExactlyOneRow(rows, pgxc.RowTo[int])
+		assert.NoError(t, err)
+		assert.Equal(t, i, id)
+	}
+}
+
+func TestPrefixedStructRowScanner(t *testing.T) {
+	type Street struct {
+		Name   string
+		Number int32 `db:"num"`
+	}
+	type Address struct {
+		Street Street `db:"street,prefix"`
+		City   string
+	}
+	type person struct {
+		Name    string
+		Address Address `db:"addr,prefix"`
+		Work    Address `db:",prefix=work_"`
+	}
+
+	expected := person{
+		Name:    "Alice",
+		Address: Address{Street: Street{"Main St", 1}, City: "Springfield"},
+		Work:    Address{Street: Street{"High St", 2}, City: "Shelbyville"},
+	}
+	{
+		rows := MakeMockRows(
+			"name,addr_street_name,addr_street_num,addr_city,work_street_name,work_street_num,work_city",
+			OneRow("Alice", "Main St", int32(1), "Springfield", "High St", int32(2), "Shelbyville"),
+		)
+		checkScanOne(t, rows, pgxc.RowToStructByName[person], nil, expected)
+	}
+	{
+		rows := MakeMockRows(
+			"name,addr_street_name,addr_street_num,addr_city,work_street_name,work_street_num,work_city",
+			OneRow("Alice", "Main St", int32(1), "Springfield", "High St", int32(2), "Shelbyville"),
+		)
+		checkScanOne(t, rows, pgxc.RowToStructByPos[person], nil, expected)
+	}
+	{
+		rows := MakeMockRows("name,addr_city", OneRow("Alice", "Springfield"))
+		expected := person{Name: "Alice", Address: Address{City: "Springfield"}}
+		checkScanOne(t, rows, pgxc.RowToStructByNameLax[person], nil, expected)
+	}
+	{
+		rows := MakeMockRows("name,street_name,city", OneRow("Alice", "Main St", "Springfield"))
+		checkInitFails(t, rows, pgxc.RowToStructByNameLax[person], nil)
+	}
+}
+
+func TestEmbeddedPointerStructRowScanner(t *testing.T) {
+	type SoftDelete struct {
+		DeletedBy string
+	}
+	type Audit struct {
+		CreatedBy string
+		UpdatedBy *string
+		*SoftDelete
+	}
+	type record struct {
+		ID int
+		*Audit
+	}
+
+	rows := MakeMockRows("id,created_by,updated_by,deleted_by", [][]any{
+		{1, "alice", nil, nil},
+		{2, "alice", Ref("bob"), "carol"},
+		{3, nil, nil, nil},
+	})
+	expected := []record{
+		{ID: 1, Audit: &Audit{CreatedBy: "alice"}},
+		{ID: 2, Audit: &Audit{CreatedBy: "alice", UpdatedBy: Ref("bob"), SoftDelete: &SoftDelete{"carol"}}},
+		{ID: 3},
+	}
+	actual, err := pgxc.CollectRows(rows, pgxc.RowToStructByName[record])
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	rows = MakeMockRows("id,deleted_by", [][]any{{1, nil}, {2, "carol"}})
+	expected = []record{
+		{ID: 1},
+		{ID: 2, Audit: &Audit{SoftDelete: &SoftDelete{"carol"}}},
+	}
+	actual, err = pgxc.CollectRows(rows, pgxc.RowToStructByNameLax[record])
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestPrefixedPointerStructRowScanner(t *testing.T) {
+	type Country struct {
+		Code string
+	}
+	type Address struct {
+		Street  string
+		City    *string
+		Country *Country `db:"country,prefix"`
+	}
+	type person struct {
+		Name    string
+		Address *Address `db:"addr,prefix"`
+	}
+
+	rows := MakeMockRows("name,addr_street,addr_city,addr_country_code", [][]any{
+		{"Alice", "Main St", Ref("Springfield"), "US"},
+		{"Bob", "High St", nil, nil},
+		{"Carol", nil, nil, nil},
+	})
+	expected := []person{
+		{"Alice", &Address{"Main St", Ref("Springfield"), &Country{"US"}}},
+		{"Bob", &Address{"High St", nil, nil}},
+		{"Carol", nil},
+	}
+	actual, err := pgxc.CollectRows(rows, pgxc.RowToStructByName[person])
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	rows = MakeMockRows("name,addr_street,addr_city,addr_country_code", [][]any{
+		{"Alice", "Main St", Ref("Springfield"), "US"},
+		{"Carol", nil, nil, nil},
+	})
+	addrs, err := pgxc.CollectRows(rows, pgxc.RowToAddrOfStructByPos[person])
+	assert.NoError(t, err)
+	assert.Equal(t, []*person{&expected[0], &expected[2]}, addrs)
+
+	// A NULL in a non-nullable field is still an error if the group is non-NULL.
+	rows = MakeMockRows("name,addr_street,addr_city", OneRow("Dave", nil, Ref("Springfield")))
+	_, err = pgxc.CollectRows(rows, pgxc.RowToStructByNameLax[person])
+	assert.Error(t, err)
+}
+
+func TestGroupedPointerStructRowScanner(t *testing.T) {
+	type Order struct {
+		OrderID int
+		Total   *int
+	}
+	type customer struct {
+		Name      string
+		LastOrder *Order `db:",group"`
+	}
+
+	rows := MakeMockRows("name,order_id,total", [][]any{
+		{"Alice", 1, Ref(100)},
+		{"Bob", 2, nil},
+		{"Carol", nil, nil},
+	})
+	expected := []customer{
+		{"Alice", &Order{1, Ref(100)}},
+		{"Bob", &Order{2, nil}},
+		{"Carol", nil},
+	}
+	actual, err := pgxc.CollectRows(rows, pgxc.RowToStructByName[customer])
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	// Without the tag, the pointer is mapped to a single column.
+	type untagged struct {
+		Name      string
+		LastOrder *Order
+	}
+	rows = MakeMockRows("name,order_id,total", OneRow("Alice", 1, Ref(100)))
+	_, err = pgxc.CollectRows(rows, pgxc.RowToStructByName[untagged])
+	assert.Error(t, err)
+}
+
+func TestRestStructRowScanner(t *testing.T) {
+	type person struct {
+		Name  string
+		Extra map[string]any `db:",rest"`
+	}
+	type textPerson struct {
+		Name  string
+		Extra map[string]string `db:",rest"`
+	}
+
+	rows := MakeMockRows("name,age,nickname", [][]any{
+		{"Alice", int32(30), "Al"},
+		{"Bob", int32(25), nil},
+	})
+	expected := []person{
+		{"Alice", map[string]any{"age": int32(30), "nickname": "Al"}},
+		{"Bob", map[string]any{"age": int32(25), "nickname": nil}},
+	}
+	actual, err := pgxc.CollectRows(rows, pgxc.RowToStructByName[person])
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	rows.Reset()
+	textActual, err := pgxc.CollectRows(rows, pgxc.RowToStructByNameLax[textPerson])
+	assert.NoError(t, err)
+	assert.Equal(t, []textPerson{
+		{"Alice", map[string]string{"age": "30", "nickname": "Al"}},
+		{"Bob", map[string]string{"age": "25"}},
+	}, textActual)
+
+	// Positional scanners don't map any columns to the rest field.
+	rows = MakeMockRows("name", OneRow("Alice"))
+	checkScanOne(t, rows, pgxc.RowToStructByPos[person], nil, person{Name: "Alice"})
+
+	{
+		type invalid struct {
+			Name  string
+			Extra map[string]int `db:",rest"`
+		}
+		rows := MakeMockRows("name,age", OneRow("Alice", 30))
+		checkInitFails(t, rows, pgxc.RowToStructByNameLax[invalid], nil)
+	}
+	{
+		type multiple struct {
+			Name   string
+			Extra  map[string]any    `db:",rest"`
+			Extra2 map[string]string `db:",rest"`
+		}
+		rows := MakeMockRows("name,age", OneRow("Alice", 30))
+		checkInitFails(t, rows, pgxc.RowToStructByName[multiple], nil)
+	}
+	{
+		// Duplicate columns can't both be kept in the rest field.
+		rows := MakeMockRows("name,age,age", OneRow("Alice", 30, 31))
+		checkInitFails(t, rows, pgxc.RowToStructByNameLax[person], nil)
+	}
+}
+
+func TestAmbiguousStructRowScanner(t *testing.T) {
+	{
+		// Two fields match the same column after case-folding.
+		type folded struct {
+			UserID int
+			Userid int `db:"userid"`
+		}
+		rows := MakeMockRows("userid", OneRow(1))
+		checkInitFails(t, rows, pgxc.RowToStructByNameLax[folded], nil)
+	}
+	{
+		// Embedded structs with the same field name.
+		type A struct{ ID int }
+		type B struct{ ID int }
+		type embedded struct {
+			A
+			B
+		}
+		rows := MakeMockRows("id", OneRow(1))
+		checkInitFails(t, rows, pgxc.RowToStructByName[embedded], nil)
+	}
+	{
+		// A field shadows the fields of embedded structs matching the same column.
+		type A struct {
+			ID   int
+			Name string
+		}
+		type B struct{ ID int }
+		type override struct {
+			A
+			B
+			ID int
+		}
+		rows := MakeMockRows("id,name", OneRow(1, "Alice"))
+		expected := override{A: A{Name: "Alice"}, ID: 1}
+		checkScanOne(t, rows, pgxc.RowToStructByName[override], nil, expected)
+	}
+	{
+		// A shadowed field tagged required is still required.
+		type A struct {
+			ID int `db:",required"`
+		}
+		type override struct {
+			A
+			ID int
+		}
+		rows := MakeMockRows("id", OneRow(1))
+		checkInitFails(t, rows, pgxc.RowToStructByNameLax[override], nil)
+	}
+	{
+		// The fields of prefixed structs aren't promoted, so don't shadow or get shadowed.
+		type address struct{ Street string }
+		type prefixed struct {
+			AddrStreet string
+			Addr       address `db:"addr,prefix"`
+		}
+		rows := MakeMockRows("addr_street", OneRow("Main St"))
+		checkInitFails(t, rows, pgxc.RowToStructByName[prefixed], nil)
+	}
+	{
+		// Duplicate columns match the same field.
+		type person struct {
+			ID   int
+			Name string
+		}
+		rows := MakeMockRows("id,name,id", OneRow(1, "Alice", 2))
+		checkInitFails(t, rows, pgxc.RowToStructByName[person], nil)
+		rows = MakeMockRows("user_id,userid", OneRow(1, 2))
+		checkInitFails(t, rows, pgxc.RowToStructByNameLax[struct{ UserID int }], nil)
+	}
+}
+
+func TestOptionalRequiredStructRowScanner(t *testing.T) {
+	type person struct {
+		ID       int    `db:",required"`
+		Name     string `db:"full_name,required"`
+		Nickname string `db:",optional"`
+		Age      int32
+	}
+
+	{
+		rows := MakeMockRows("id,full_name,age", OneRow(1, "Alice", int32(30)))
+		checkScanOne(t, rows, pgxc.RowToStructByName[person], nil, person{1, "Alice", "", 30})
+		rows.Reset()
+		checkScanOne(t, rows, pgxc.RowToStructByNameLax[person], nil, person{1, "Alice", "", 30})
+	}
+	{
+		// Age isn't optional in strict specs.
+		rows := MakeMockRows("id,full_name", OneRow(1, "Alice"))
+		checkInitFails(t, rows, pgxc.RowToStructByName[person], nil)
+		rows.Reset()
+		checkScanOne(t, rows, pgxc.RowToStructByNameLax[person], nil, person{1, "Alice", "", 0})
+	}
+	{
+		// ID is required in lax specs.
+		rows := MakeMockRows("full_name,nickname", OneRow("Alice", "Al"))
+		_, err := pgxc.CollectRows(rows, pgxc.RowToStructByNameLax[person])
+		var mappingErr *pgxc.MappingError
+		assert.True(t, errors.As(err, &mappingErr))
+		assert.Equal(t, []string{"ID"}, mappingErr.MissingFields)
+	}
+}
+
+func TestNullZeroRowScanner(t *testing.T) {
+	{
+		rows := MakeMockRows("name", OneCol[any]("Alice", nil))
+		actual, err := pgxc.CollectRows(rows, pgxc.RowToNullZero[string])
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Alice", ""}, actual)
+
+		rows.Reset()
+		addrs, err := pgxc.CollectRows(rows, pgxc.RowToAddrOfNullZero[string])
+		assert.NoError(t, err)
+		assert.Equal(t, []*string{Ref("Alice"), Ref("")}, addrs)
+
+		rows.Reset()
+		_, err = pgxc.CollectRows(rows, pgxc.RowTo[string])
+		assert.Error(t, err)
+	}
+
+	type person struct {
+		Name string `db:",nullzero"`
+		Age  int32
+	}
+	rows := MakeMockRows("name,age", [][]any{
+		{"Alice", int32(30)},
+		{nil, int32(25)},
+	})
+	actual, err := pgxc.CollectRows(rows, pgxc.RowToStructByName[person])
+	assert.NoError(t, err)
+	assert.Equal(t, []person{{"Alice", 30}, {"", 25}}, actual)
+
+	// The receiver is zeroed, rather than left unchanged.
+	rows.Reset()
+	actual = []person{{}, {"Bob", 40}}
+	actual, err = pgxc.AppendRows(actual[:0], rows, pgxc.RowToStructByPos[person])
+	assert.NoError(t, err)
+	assert.Equal(t, []person{{"Alice", 30}, {"", 25}}, actual)
+
+	// Age isn't nullzero.
+	rows = MakeMockRows("name,age", OneRow("Alice", nil))
+	_, err = pgxc.CollectRows(rows, pgxc.RowToStructByName[person])
+	assert.Error(t, err)
+
+	// The mapper option applies to all fields.
+	mapper := pgxc.NewMapper(pgxc.WithNullZero(), pgxc.WithUnsafeFieldAccess())
+	rows.Reset()
+	checkScanOne(t, rows, pgxc.RowToStructByNameWith[person](mapper), nil, person{"Alice", 0})
+}
+
+func TestJSONStructRowScanner(t *testing.T) {
+	type payload struct {
+		Kind  string   `json:"kind"`
+		Items []string `json:"items"`
+	}
+	type event struct {
+		ID      int
+		Payload payload        `db:",json"`
+		Meta    map[string]any `db:"meta,json"`
+	}
+
+	rows := MakeMockRows("id,payload,meta", [][]any{
+		{1, `{"kind":"created","items":["a","b"]}`, []byte(`{"n":1}`)},
+		{2, `{"kind":"deleted"}`, nil},
+	})
+	expected := []event{
+		{1, payload{"created", []string{"a", "b"}}, map[string]any{"n": float64(1)}},
+		{2, payload{"deleted", nil}, nil},
+	}
+	actual, err := pgxc.CollectRows(rows, pgxc.RowToStructByName[event])
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	rows = MakeMockRows("id,payload,meta", OneRow(1, `{"kind":`, nil))
+	_, err = pgxc.CollectRows(rows, pgxc.RowToStructByPos[event])
+	assert.Error(t, err)
+
+	var decoded []string
+	mapper := pgxc.NewMapper(pgxc.WithJSONDecoder(func(data []byte, v any) error {
+		decoded = append(decoded, string(data))
+		return json.Unmarshal(data, v)
+	}))
+	rows = MakeMockRows("id,payload", OneRow(1, `{"kind":"created"}`))
+	_, err = pgxc.CollectRows(rows, pgxc.RowToStructByNameLaxWith[event](mapper))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"kind":"created"}`}, decoded)
+}
+
+type email struct {
+	user, domain string
+}
+
+func (e *email) UnmarshalText(text []byte) error {
+	user, domain, ok := strings.Cut(string(text), "@")
+	if !ok {
+		return fmt.Errorf("invalid email %q", text)
+	}
+	*e = email{user, domain}
+	return nil
+}
+
+// checksum implements both TextUnmarshaler and BinaryUnmarshaler.
+type checksum [2]byte
+
+func (c *checksum) UnmarshalText(text []byte) error {
+	_, err := hex.Decode(c[:], text)
+	return err
+}
+
+func (c *checksum) UnmarshalBinary(data []byte) error {
+	if len(data) != len(c) {
+		return fmt.Errorf("invalid checksum length %d", len(data))
+	}
+	copy(c[:], data)
+	return nil
+}
+
+func TestUnmarshalerStructRowScanner(t *testing.T) {
+	type account struct {
+		Email     email
+		Backup    *email
+		Sum       checksum
+		CreatedAt time.Time
+	}
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	textOIDs := []uint32{pgtype.TextOID, pgtype.VarcharOID, pgtype.TextOID, pgtype.TimestamptzOID}
+	makeRows := func(data [][]any, oids []uint32) *MockRows {
+		rows := MakeMockRows("email,backup,sum,created_at", data)
+		for i, oid := range oids {
+			rows.FieldDescriptions()[i].DataTypeOID = oid
+		}
+		return rows
+	}
+
+	rows := makeRows([][]any{
+		{"alice@example.com", "al@example.com", "abcd", created},
+		{"bob@example.com", nil, "0102", created},
+	}, textOIDs)
+	expected := []account{
+		{email{"alice", "example.com"}, &email{"al", "example.com"}, checksum{0xab, 0xcd}, created},
+		{email{"bob", "example.com"}, nil, checksum{1, 2}, created},
+	}
+	actual, err := pgxc.CollectRows(rows, pgxc.RowToStructByName[account])
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	// bytea columns are decoded with UnmarshalBinary.
+	byteaOIDs := []uint32{pgtype.TextOID, pgtype.TextOID, pgtype.ByteaOID, pgtype.TimestamptzOID}
+	rows = makeRows(OneRow("a@b", nil, []byte{1, 2}, created), byteaOIDs)
+	checkScanOne(
+		t,
+		rows,
+		pgxc.RowToStructByPos[account],
+		nil,
+		account{email{"a", "b"}, nil, checksum{1, 2}, created},
+	)
+
+	rows = makeRows(OneRow("invalid", nil, "0102", created), textOIDs)
+	_, err = pgxc.CollectRows(rows, pgxc.RowToStructByName[account])
+	assert.Error(t, err)
+
+	rows = makeRows(OneRow(nil, nil, "0102", created), textOIDs)
+	_, err = pgxc.CollectRows(rows, pgxc.RowToStructByName[account])
+	assert.Error(t, err)
+
+	// Columns which aren't text or bytea are scanned by pgx, not decoded.
+	type player struct {
+		Name  string
+		Level level
+	}
+	rows = MakeMockRows("name,level", OneRow("Alice", 2))
+	rows.FieldDescriptions()[1].DataTypeOID = pgtype.Int8OID
+	checkScanOne(t, rows, pgxc.RowToStructByName[player], nil, player{"Alice", 2})
+
+	rows = MakeMockRows("name,level", OneRow("Alice", "high"))
+	rows.FieldDescriptions()[1].DataTypeOID = pgtype.TextOID
+	checkScanOne(t, rows, pgxc.RowToStructByName[player], nil, player{"Alice", 3})
+}
+
+// level is an int which is parsed from its name as text.
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 3
+	default:
+		return fmt.Errorf("bad level %q", text)
+	}
+	return nil
+}
+
+type color int
+
+const (
+	red color = iota + 1
+	green
+)
+
+func parseColor(s string) (color, error) {
+	switch s {
+	case "red":
+		return red, nil
+	case "green":
+		return green, nil
+	default:
+		return 0, fmt.Errorf("invalid color %q", s)
+	}
+}
+
+type timeout time.Duration
+
+func TestRegisterConverter(t *testing.T) {
+	pgxc.RegisterConverter(parseColor)
+	pgxc.RegisterConverter(func(ms int64) (timeout, error) {
+		return timeout(time.Duration(ms) * time.Millisecond), nil
+	})
+
+	type widget struct {
+		Name    string
+		Color   color
+		Timeout timeout
+	}
+	rows := MakeMockRows("name,color,timeout", [][]any{
+		{"a", "red", int64(1500)},
+		{"b", "green", int64(0)},
+	})
+	expected := []widget{
+		{"a", red, timeout(1500 * time.Millisecond)},
+		{"b", green, 0},
+	}
+	actual, err := pgxc.CollectRows(rows, pgxc.RowToStructByName[widget])
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	rows = MakeMockRows("name,color,timeout", OneRow("c", "blue", int64(0)))
+	_, err = pgxc.CollectRows(rows, pgxc.RowToStructByPos[widget])
+	assert.Error(t, err)
+
+	rows = MakeMockRows("color", OneCol("green", "red"))
+	colors, err := pgxc.CollectRows(rows, pgxc.RowTo[color])
+	assert.NoError(t, err)
+	assert.Equal(t, []color{green, red}, colors)
+
+	rows.Reset()
+	colorAddrs, err := pgxc.CollectRows(rows, pgxc.RowToAddrOf[color])
+	assert.NoError(t, err)
+	assert.Equal(t, []*color{Ref(green), Ref(red)}, colorAddrs)
+
+	// Pointer fields are converted with the converter into their element type.
+	type nullableWidget struct {
+		Name  string
+		Color *color
+	}
+	rows = MakeMockRows("name,color", [][]any{{"a", "red"}, {"b", nil}})
+	nullables, err := pgxc.CollectRows(rows, pgxc.RowToStructByName[nullableWidget])
+	assert.NoError(t, err)
+	assert.Equal(t, []nullableWidget{{"a", Ref(red)}, {"b", nil}}, nullables)
+
+	rows = MakeMockRows("name,color", OneRow("c", "purple"))
+	_, err = pgxc.CollectRows(rows, pgxc.RowToStructByName[nullableWidget])
+	assert.Error(t, err)
+}
+
+func TestScanOneRowInto(t *testing.T) {
+	type person struct {
+		ID    int
+		Name  string
+		Email string
+	}
+
+	{
+		dst := person{1, "Alice", "alice@example.com"}
+		rows := MakeMockRows("name", OneRow("Alicia"))
+		err := pgxc.ScanOneRowIntoLax(rows, &dst)
+		assert.NoError(t, err)
+		assert.Equal(t, person{1, "Alicia", "alice@example.com"}, dst)
+		assert.True(t, rows.IsClosed())
+	}
+	{
+		dst := person{1, "Alice", "alice@example.com"}
+		rows := MakeMockRows("email,name", OneRow("al@example.com", "Al"))
+		err := pgxc.ScanOneRowInto(rows, pgxc.RowToStructByNameLax[person], &dst)
+		assert.NoError(t, err)
+		assert.Equal(t, person{1, "Al", "al@example.com"}, dst)
+	}
+	{
+		// dst is unchanged on error.
+		dst := person{1, "Alice", "alice@example.com"}
+		rows := MakeMockRows("name,email", OneRow("Alicia", nil))
+		err := pgxc.ScanOneRowIntoLax(rows, &dst)
+		assert.Error(t, err)
+		assert.Equal(t, person{1, "Alice", "alice@example.com"}, dst)
+
+		rows = MakeMockRows("name", nil)
+		err = pgxc.ScanOneRowIntoLax(rows, &dst)
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+		assert.Equal(t, person{1, "Alice", "alice@example.com"}, dst)
+
+		rows = MakeMockRows("name,phone", OneRow("Alicia", "555"))
+		err = pgxc.ScanOneRowIntoLax(rows, &dst)
+		assert.Error(t, err)
+		assert.Equal(t, person{1, "Alice", "alice@example.com"}, dst)
+	}
+	{
+		// Embedded pointers without any columns are left unchanged.
+		type Audit struct {
+			CreatedBy string
+		}
+		type record struct {
+			ID   int
+			Name string
+			*Audit
+		}
+		dst := record{1, "a", &Audit{"admin"}}
+		rows := MakeMockRows("name", OneRow("b"))
+		err := pgxc.ScanOneRowIntoLax(rows, &dst)
+		assert.NoError(t, err)
+		assert.Equal(t, record{1, "b", &Audit{"admin"}}, dst)
+
+		rows = MakeMockRows("name,created_by", OneRow("c", nil))
+		err = pgxc.ScanOneRowIntoLax(rows, &dst)
+		assert.NoError(t, err)
+		assert.Equal(t, record{1, "c", nil}, dst)
+	}
+	{
+		var n int
+		rows := MakeMockRows("n", OneCol(5))
+		err := pgxc.ScanOneRowInto(rows, pgxc.RowTo[int], &n)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, n)
+	}
 }
@@ -0,0 +1,160 @@
+package pgx_collect
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	. "github.com/zolstein/pgx-collect/internal"
+)
+
+// UnionVariant describes one of the concrete types that a RowToUnion spec can scan into.
+// UnionVariants are created with Variant.
+type UnionVariant[I any] struct {
+	value      string
+	newScanner func() variantScanner[I]
+}
+
+// Variant returns a UnionVariant that scans rows whose discriminator column equals value into a T.
+// T must be a struct, and either T or *T must implement I. If T implements I, the rows are
+// returned as T values; otherwise they are returned as *T values.
+func Variant[I any, T any](value string) UnionVariant[I] {
+	return UnionVariant[I]{
+		value: value,
+		newScanner: func() variantScanner[I] {
+			vs := &structVariantScanner[I, T]{}
+			vs.mapper = DefaultFieldMapper
+			return vs
+		},
+	}
+}
+
+// RowToUnion scans a row into one of several concrete types implementing the interface I.
+// The value of the discriminator column selects which of the variants the row is scanned into.
+// Each variant is matched to the row by name, as with RowToStructByNameLax, except that columns
+// which don't correspond to a field of the variant are ignored. Fields tagged "required" must
+// still have a corresponding column.
+func RowToUnion[I any](discriminator string, variants ...UnionVariant[I]) RowSpec[I] {
+	return func() rowSpecRes[I] {
+		return rowSpecRes[I]{
+			fn: func() Scanner[I] {
+				return &unionScanner[I]{
+					discriminator: discriminator,
+					variants:      variants,
+				}
+			},
+		}
+	}
+}
+
+type unionScanner[I any] struct {
+	discriminator string
+	variants      []UnionVariant[I]
+	scanners      map[string]variantScanner[I]
+	value         string
+	valueTargets  []any
+}
+
+var _ Scanner[any] = (*unionScanner[any])(nil)
+
+func (rs *unionScanner[I]) Initialize(rows pgx.Rows) error {
+	typ := typeFor[I]()
+	if typ.Kind() != reflect.Interface {
+		return fmt.Errorf("generic type '%s' is not an interface", typ.Name())
+	}
+	fldDescs := rows.FieldDescriptions()
+	pos := -1
+	for i := range fldDescs {
+		if fldDescs[i].Name == rs.discriminator {
+			pos = i
+			break
+		}
+	}
+	if pos == -1 {
+		return fmt.Errorf("cannot find discriminator %s in returned row", rs.discriminator)
+	}
+	rs.valueTargets = make([]any, len(fldDescs))
+	rs.valueTargets[pos] = &rs.value
+
+	rs.scanners = make(map[string]variantScanner[I], len(rs.variants))
+	for _, v := range rs.variants {
+		if _, ok := rs.scanners[v.value]; ok {
+			return fmt.Errorf("multiple variants for discriminator value %q", v.value)
+		}
+		scanner := v.newScanner()
+		if err := scanner.initialize(fldDescs); err != nil {
+			return err
+		}
+		rs.scanners[v.value] = scanner
+	}
+	return nil
+}
+
+func (rs *unionScanner[I]) ScanRowInto(receiver *I, rows pgx.Rows) error {
+	// Scan only the discriminator first, then rescan the row into the selected variant.
+	if err := rows.Scan(rs.valueTargets...); err != nil {
+		return err
+	}
+	scanner, ok := rs.scanners[rs.value]
+	if !ok {
+		return fmt.Errorf("no variant for %s value %q", rs.discriminator, rs.value)
+	}
+	return scanner.scanRowInto(receiver, rows)
+}
+
+// variantScanner scans a row into a single variant of a union.
+type variantScanner[I any] interface {
+	initialize(fldDescs []pgconn.FieldDescription) error
+	scanRowInto(receiver *I, rows pgx.Rows) error
+}
+
+type structVariantScanner[I any, T any] struct {
+	structScanner[T]
+	addr bool
+}
+
+func (vs *structVariantScanner[I, T]) initialize(fldDescs []pgconn.FieldDescription) error {
+	typ := typeFor[T]()
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("variant type '%s' is not a struct", typ.Name())
+	}
+	var zero T
+	if _, ok := any(zero).(I); ok {
+		vs.addr = false
+	} else if _, ok := any(&zero).(I); ok {
+		vs.addr = true
+	} else {
+		return fmt.Errorf(
+			"variant type '%s' does not implement '%s'",
+			typ.Name(),
+			typeFor[I]().Name(),
+		)
+	}
+	var mismatch Mismatch
+	var err error
+	vs.scanFields, mismatch, err = vs.mapper.GetStructRowFieldsByName(typ, fldDescs)
+	if err != nil {
+		return err
+	}
+	// Columns for other variants are ignored, but fields tagged "required" must have a column.
+	mismatch.UnmatchedColumns = nil
+	if mappingErr := newMappingError(typ, mismatch, true); mappingErr != nil {
+		return mappingErr
+	}
+	return vs.initializeScanState(fldDescs)
+}
+
+func (vs *structVariantScanner[I, T]) scanRowInto(receiver *I, rows pgx.Rows) error {
+	value := new(T)
+	if err := vs.ScanRowInto(value, rows); err != nil {
+		return err
+	}
+	if vs.addr {
+		*receiver = any(value).(I)
+	} else {
+		*receiver = any(*value).(I)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package pgx_collect_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pgxc "github.com/zolstein/pgx-collect"
+	. "github.com/zolstein/pgx-collect/internal/testutils"
+)
+
+type event interface {
+	eventKind() string
+}
+
+type createdEvent struct {
+	Kind string
+	ID   int
+	Name string
+}
+
+func (createdEvent) eventKind() string { return "created" }
+
+type deletedEvent struct {
+	ID     int
+	Reason string
+}
+
+func (*deletedEvent) eventKind() string { return "deleted" }
+
+type archivedEvent struct {
+	ID int `db:",required"`
+}
+
+func (archivedEvent) eventKind() string { return "archived" }
+
+func TestUnionRowScanner(t *testing.T) {
+	rowTo := pgxc.RowToUnion[event](
+		"kind",
+		pgxc.Variant[event, createdEvent]("created"),
+		pgxc.Variant[event, deletedEvent]("deleted"),
+	)
+
+	t.Run("success", func(t *testing.T) {
+		rows := MakeMockRows("kind,id,name,reason", [][]any{
+			{"created", 1, "Alice", nil},
+			{"deleted", 1, nil, "spam"},
+		})
+		expected := []event{
+			createdEvent{Kind: "created", ID: 1, Name: "Alice"},
+			&deletedEvent{ID: 1, Reason: "spam"},
+		}
+
+		actual, err := pgxc.CollectRows(rows, rowTo)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("unknown-value", func(t *testing.T) {
+		rows := MakeMockRows("kind,id", OneRow("updated", 1))
+		_, err := pgxc.CollectRows(rows, rowTo)
+		assert.Error(t, err)
+	})
+
+	t.Run("missing-discriminator", func(t *testing.T) {
+		rows := MakeMockRows("id,name", OneRow(1, "Alice"))
+		_, err := pgxc.CollectRows(rows, rowTo)
+		assert.Error(t, err)
+	})
+
+	t.Run("duplicate-value", func(t *testing.T) {
+		rows := MakeMockRows("kind,id", OneRow("created", 1))
+		_, err := pgxc.CollectRows(rows, pgxc.RowToUnion[event](
+			"kind",
+			pgxc.Variant[event, createdEvent]("created"),
+			pgxc.Variant[event, deletedEvent]("created"),
+		))
+		assert.Error(t, err)
+	})
+
+	t.Run("missing-required", func(t *testing.T) {
+		rowTo := pgxc.RowToUnion[event](
+			"kind",
+			pgxc.Variant[event, createdEvent]("created"),
+			pgxc.Variant[event, archivedEvent]("archived"),
+		)
+		rows := MakeMockRows("kind,name", OneRow("created", "Alice"))
+		_, err := pgxc.CollectRows(rows, rowTo)
+		var mappingErr *pgxc.MappingError
+		assert.True(t, errors.As(err, &mappingErr))
+		assert.Equal(t, []string{"ID"}, mappingErr.MissingFields)
+	})
+
+	t.Run("not-implemented", func(t *testing.T) {
+		type other struct {
+			ID int
+		}
+		rows := MakeMockRows("kind,id", OneRow("other", 1))
+		_, err := pgxc.CollectRows(rows, pgxc.RowToUnion[event](
+			"kind",
+			pgxc.Variant[event, other]("other"),
+		))
+		assert.Error(t, err)
+	})
+}